@@ -0,0 +1,108 @@
+// Package alert remembers which one-off alerts have already fired so that each
+// one is only raised once, even if the updater is restarted.
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tracker records when each alert key fired.
+type Tracker struct {
+	filePath string
+	fired    map[string]time.Time
+	changed  bool
+}
+
+// Load reads the alerts that have already fired from filePath. A missing file
+// is treated as no alerts having fired.
+func Load(filePath string) (*Tracker, error) {
+	tracker := &Tracker{filePath: filePath, fired: make(map[string]time.Time)}
+
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &tracker.fired); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	return tracker, nil
+}
+
+// Fire marks key as fired. True is returned only the first time, which is the
+// caller's cue to actually raise the alert.
+func (tracker *Tracker) Fire(key string) bool {
+	if _, done := tracker.fired[key]; done {
+		return false
+	}
+	tracker.fired[key] = time.Now()
+	tracker.changed = true
+	return true
+}
+
+// Rearm forgets that key fired so it can fire again. This is used when the
+// condition behind an alert stops being true.
+func (tracker *Tracker) Rearm(key string) {
+	if _, done := tracker.fired[key]; done {
+		delete(tracker.fired, key)
+		tracker.changed = true
+	}
+}
+
+// Fired returns the time key fired, if it has.
+func (tracker *Tracker) Fired(key string) (time.Time, bool) {
+	firedAt, done := tracker.fired[key]
+	return firedAt, done
+}
+
+// Save writes the Tracker back to its file if anything changed.
+func (tracker *Tracker) Save() error {
+	if !tracker.changed {
+		return nil
+	}
+
+	err := os.MkdirAll(path.Dir(tracker.filePath), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(tracker.fired, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(tracker.filePath, content, 0644); err != nil {
+		return err
+	}
+
+	tracker.changed = false
+	return nil
+}
+
+// ParseThresholds reads a comma separated list of percentages such as
+// "80,95,100" and returns them in ascending order.
+func ParseThresholds(spec string) ([]float64, error) {
+	var thresholds []float64
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSuffix(strings.TrimSpace(field), "%")
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q", field)
+		}
+		thresholds = append(thresholds, value)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
@@ -0,0 +1,276 @@
+// Package archive freezes a closed campaign into a final archive, signed so
+// that the published cell allocation can be checked against it later.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FileName is the name of the archive inside the state directory.
+	FileName string = "final.tar.gz"
+	// SignatureFile holds the hex encoded signature of the archive.
+	SignatureFile string = "final.sig"
+	// KeyFile is the usual name of the private key used to sign archives.
+	// It's created the first time a campaign is frozen, and must be kept
+	// outside of every state directory so it's never archived or published.
+	KeyFile string = "signing.key"
+	// PublicKeyFile holds the hex encoded public key for checking signatures.
+	// It's written next to the private key and copied into the state
+	// directory with each archive.
+	PublicKeyFile string = "signing.pub"
+
+	manifestName string = "manifest.json"
+)
+
+// Manifest describes what went into an archive. It's stored inside the
+// archive next to the files it lists.
+type Manifest struct {
+	Campaign string            `json:"campaign,omitempty"`
+	FrozenAt time.Time         `json:"frozen_at"`
+	Files    map[string]string `json:"files"`
+}
+
+// Frozen reports whether the campaign in stateDir has already been archived.
+func Frozen(stateDir string) bool {
+	for _, name := range []string{FileName, SignatureFile} {
+		if _, err := os.Stat(path.Join(stateDir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Create writes the files into a gzipped tarball in stateDir, along with a
+// manifest of their SHA-256 hashes, and signs it with the private key in
+// keyFile. Files are stored under their base names, and any that don't exist
+// are left out. The key file can't be inside stateDir.
+func Create(stateDir, campaign, keyFile string, files []string) (*Manifest, error) {
+	if keyFile == "" {
+		return nil, errors.New("no signing key given")
+	}
+	if inside(keyFile, stateDir) {
+		return nil, fmt.Errorf("the signing key %s must be kept outside of the state directory", keyFile)
+	}
+	key, err := loadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Campaign: campaign, FrozenAt: time.Now(), Files: make(map[string]string)}
+	contents := make(map[string][]byte)
+	for _, fileName := range files {
+		content, err := ioutil.ReadFile(fileName)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Base(fileName)
+		sum := sha256.Sum256(content)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+		contents[name] = content
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	zipper := gzip.NewWriter(&buffer)
+	tarball := tar.NewWriter(zipper)
+	add := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: manifest.FrozenAt}
+		if err := tarball.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tarball.Write(content)
+		return err
+	}
+	if err := add(manifestName, manifestJSON); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := add(name, contents[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tarball.Close(); err != nil {
+		return nil, err
+	}
+	if err := zipper.Close(); err != nil {
+		return nil, err
+	}
+
+	// The signature is written last, since its presence marks the campaign
+	// as frozen.
+	signature := ed25519.Sign(key, buffer.Bytes())
+	if err := ioutil.WriteFile(path.Join(stateDir, FileName), buffer.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path.Join(stateDir, SignatureFile), []byte(hex.EncodeToString(signature)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	if err := ioutil.WriteFile(path.Join(stateDir, PublicKeyFile), []byte(hex.EncodeToString(public)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Verify checks the archive in stateDir against its signature using the public
+// key in publicKeyFile, and returns the manifest stored inside it. If
+// publicKeyFile is empty, the copy of the public key in stateDir is used,
+// which only shows that the archive wasn't damaged. A copy of the public key
+// from somewhere else also shows that nobody replaced the archive.
+func Verify(stateDir, publicKeyFile string) (*Manifest, error) {
+	if publicKeyFile == "" {
+		publicKeyFile = path.Join(stateDir, PublicKeyFile)
+	}
+	content, err := ioutil.ReadFile(path.Join(stateDir, FileName))
+	if err != nil {
+		return nil, err
+	}
+	signature, err := readHex(path.Join(stateDir, SignatureFile))
+	if err != nil {
+		return nil, err
+	}
+	public, err := readHex(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s: invalid public key", publicKeyFile)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(public), content, signature) {
+		return nil, errors.New("the archive doesn't match its signature")
+	}
+	return readManifest(content)
+}
+
+// readManifest returns the manifest stored in the archive content.
+func readManifest(content []byte) (*Manifest, error) {
+	unzipper, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	tarball := tar.NewReader(unzipper)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the archive has no %s", manifestName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != manifestName {
+			continue
+		}
+
+		manifest := &Manifest{}
+		if err := json.NewDecoder(tarball).Decode(manifest); err != nil {
+			return nil, fmt.Errorf("%s: %v", manifestName, err)
+		}
+		return manifest, nil
+	}
+}
+
+// Check reports whether the file at fileName is the one of the same name
+// listed in the manifest.
+func (manifest *Manifest) Check(fileName string) error {
+	name := path.Base(fileName)
+	expected, ok := manifest.Files[name]
+	if !ok {
+		return fmt.Errorf("%s isn't in the archive", name)
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != expected {
+		return fmt.Errorf("%s doesn't match the archived copy", fileName)
+	}
+	return nil
+}
+
+// PublicKeyPath returns where the public key for the private key in keyFile
+// is kept.
+func PublicKeyPath(keyFile string) string {
+	return strings.TrimSuffix(keyFile, path.Ext(keyFile)) + path.Ext(PublicKeyFile)
+}
+
+// loadKey reads the signing key from keyFile, creating one along with its
+// public key if there isn't one yet.
+func loadKey(keyFile string) (ed25519.PrivateKey, error) {
+	seed, err := readHex(keyFile)
+	if err == nil {
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s: invalid signing key", keyFile)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(private.Seed())+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(PublicKeyPath(keyFile), []byte(hex.EncodeToString(public)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return private, nil
+}
+
+// inside reports whether fileName is somewhere inside dir.
+func inside(fileName, dir string) bool {
+	absFile, errFile := filepath.Abs(fileName)
+	absDir, errDir := filepath.Abs(dir)
+	if errFile != nil || errDir != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readHex reads a file holding a single hex encoded value.
+func readHex(fileName string) ([]byte, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(string(bytes.TrimSpace(content)))
+}
@@ -0,0 +1,90 @@
+package archive_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/archive"
+)
+
+func TestCreateAndVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyDir, err := ioutil.TempDir("", "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyDir)
+	keyFile := path.Join(keyDir, archive.KeyFile)
+
+	dataFile := path.Join(dir, "data.json")
+	ioutil.WriteFile(dataFile, []byte(`{"cells":[]}`), 0644)
+
+	if archive.Frozen(dir) {
+		t.Error("For", "Frozen()", "expected", false, "got", true)
+	}
+
+	// The key can't be kept where it would be archived or published.
+	if _, err := archive.Create(dir, "car-8", path.Join(dir, "keys", archive.KeyFile), []string{dataFile}); err == nil {
+		t.Error("For", "a key in the state directory", "expected", "an error", "got", nil)
+	}
+
+	manifest, err := archive.Create(dir, "car-8", keyFile, []string{dataFile, path.Join(dir, "missing.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Error("For", "manifest", "expected", 1, "got", len(manifest.Files))
+	}
+	if !archive.Frozen(dir) {
+		t.Error("For", "Frozen()", "expected", true, "got", false)
+	}
+	for _, name := range []string{archive.KeyFile, "keys"} {
+		if _, err := os.Stat(path.Join(dir, name)); err == nil {
+			t.Error("For", name, "expected", "not in the state directory", "got", "found")
+		}
+	}
+
+	for _, publicKey := range []string{"", archive.PublicKeyPath(keyFile)} {
+		verified, err := archive.Verify(dir, publicKey)
+		if err != nil {
+			t.Error("For", "Verify()", publicKey, "expected", nil, "got", err)
+			continue
+		}
+		if verified.Campaign != "car-8" || verified.Files["data.json"] != manifest.Files["data.json"] {
+			t.Error("For", "verified manifest", "expected", manifest, "got", verified)
+		}
+	}
+	if err := manifest.Check(dataFile); err != nil {
+		t.Error("For", "Check()", "expected", nil, "got", err)
+	}
+
+	// A different key doesn't verify the archive.
+	otherDir, _ := ioutil.TempDir("", "archive")
+	defer os.RemoveAll(otherDir)
+	otherKey := path.Join(keyDir, "other.key")
+	if _, err := archive.Create(otherDir, "car-9", otherKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Verify(dir, archive.PublicKeyPath(otherKey)); err == nil {
+		t.Error("For", "another key", "expected", "an error", "got", nil)
+	}
+
+	// Any change to the published file or the archive is caught.
+	ioutil.WriteFile(dataFile, []byte(`{"cells":[1]}`), 0644)
+	if err := manifest.Check(dataFile); err == nil {
+		t.Error("For", "changed data.json", "expected", "an error", "got", nil)
+	}
+	archiveFile := path.Join(dir, archive.FileName)
+	content, _ := ioutil.ReadFile(archiveFile)
+	content[len(content)-1] ^= 0xff
+	ioutil.WriteFile(archiveFile, content, 0600)
+	if _, err := archive.Verify(dir, ""); err == nil {
+		t.Error("For", "tampered archive", "expected", "an error", "got", nil)
+	}
+}
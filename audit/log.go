@@ -0,0 +1,68 @@
+// Package audit keeps an append-only record of the decisions the updater makes
+// on its own or on behalf of an operator, so that any change to who owns a cell
+// can be traced back later.
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+)
+
+// FileName is the name of the audit log inside the state directory.
+const FileName string = "audit.log"
+
+// Event is a single line of the audit log.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Subject string    `json:"subject"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// Log appends Events to a file. Each Event is written as one line of JSON so
+// the file can be read with standard tools. Events can name donors, so the
+// file is only readable by its owner.
+type Log struct {
+	filePath string
+}
+
+// Open returns a Log that writes to filePath. The file isn't created until the
+// first Event is recorded. If filePath is empty, Events are discarded.
+func Open(filePath string) *Log {
+	return &Log{filePath: filePath}
+}
+
+// Record appends an Event to the Log.
+func (log *Log) Record(action, subject, detail string) error {
+	if log.filePath == "" {
+		return nil
+	}
+	err := os.MkdirAll(path.Dir(log.filePath), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(log.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(Event{
+		Time:    time.Now(),
+		Action:  action,
+		Subject: subject,
+		Detail:  detail,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,101 @@
+// Package campaign reads the registry of fundraising campaigns an instance
+// runs. Each campaign has its own source, pricing, array and state, so two
+// cars can be fundraising at the same time.
+package campaign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+// validSlug limits slugs to what can be used safely in a URL path.
+var validSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Campaign is a single entry in the registry. Anything left empty falls back
+// to the command line settings.
+type Campaign struct {
+	// Slug identifies the campaign in URLs and directory names.
+	Slug string `json:"slug"`
+	// Name is shown to operators.
+	Name string `json:"name"`
+	// Source is the URL the patron export is downloaded from.
+	Source string `json:"source"`
+	// CellPrice is the price of a single cell in dollars.
+	CellPrice int `json:"cell_price"`
+	// Layout is a JSON file describing the campaign's cell array.
+	Layout string `json:"layout"`
+	// Reservations is a JSON file of cells held back for sponsors.
+	Reservations string `json:"reservations"`
+	// Goals is a JSON file with the campaign's goal and milestones.
+	Goals string `json:"goals"`
+	// Capacity is the most cells that may be adopted.
+	Capacity int `json:"capacity"`
+	// StateDir holds the campaign's downloads, queues and allocations.
+	StateDir string `json:"state_dir"`
+	// Output is the path the campaign's data.json is written to.
+	Output string `json:"output"`
+	// Start and End are when the campaign opens and closes, as
+	// "YYYY-MM-DD" or "YYYY-MM-DD HH:MM". Grace is how long after End late
+	// pledges are still picked up, such as "72h", before the campaign is
+	// frozen.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Grace string `json:"grace"`
+	// CarryFrom is the slug of an earlier campaign whose leftover credit is
+	// added to this one.
+	CarryFrom string `json:"carry_from"`
+	// Overlay, Payments, Matching and Recognition are the campaign's pledge
+	// overlay, payment reconciliation, matching gift rules and recognition
+	// files. Telemetry is a glob of the telemetry exports for its array.
+	// They're never shared between campaigns.
+	Overlay     string `json:"overlay"`
+	Payments    string `json:"payments"`
+	Matching    string `json:"matching"`
+	Recognition string `json:"recognition"`
+	Telemetry   string `json:"telemetry"`
+}
+
+// Load reads the campaign registry at fileName.
+func Load(fileName string) ([]*Campaign, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var campaigns []*Campaign
+	if err := json.Unmarshal(content, &campaigns); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if len(campaigns) == 0 {
+		return nil, fmt.Errorf("%s: no campaigns defined", fileName)
+	}
+
+	bySlug := make(map[string]*Campaign)
+	for _, campaign := range campaigns {
+		if !validSlug.MatchString(campaign.Slug) {
+			return nil, fmt.Errorf("%s: invalid campaign slug %q", fileName, campaign.Slug)
+		}
+		if _, dup := bySlug[campaign.Slug]; dup {
+			return nil, fmt.Errorf("%s: campaign %q is defined twice", fileName, campaign.Slug)
+		}
+		if campaign.Source == "" {
+			return nil, fmt.Errorf("%s: campaign %q has no source", fileName, campaign.Slug)
+		}
+		if campaign.CellPrice < 0 {
+			return nil, fmt.Errorf("%s: campaign %q has a negative cell price", fileName, campaign.Slug)
+		}
+		bySlug[campaign.Slug] = campaign
+	}
+	for _, campaign := range campaigns {
+		if campaign.CarryFrom == "" {
+			continue
+		}
+		if _, ok := bySlug[campaign.CarryFrom]; !ok || campaign.CarryFrom == campaign.Slug {
+			return nil, fmt.Errorf("%s: campaign %q carries from unknown campaign %q", fileName, campaign.Slug, campaign.CarryFrom)
+		}
+	}
+
+	return campaigns, nil
+}
@@ -0,0 +1,36 @@
+package campaign_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/campaign"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "campaign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registries := map[string]bool{
+		`[{"slug": "car-7", "source": "https://example.com/7"},
+		  {"slug": "car-8", "source": "https://example.com/8", "carry_from": "car-7"}]`: true,
+		`[{"slug": "Car 8", "source": "https://example.com/8"}]`: false,
+		`[{"slug": "car-8"}]`: false,
+		`[{"slug": "car-8", "source": "https://example.com/8"},
+		  {"slug": "car-8", "source": "https://example.com/8"}]`: false,
+		`[{"slug": "car-8", "source": "https://example.com/8", "carry_from": "car-6"}]`: false,
+	}
+	fileName := path.Join(dir, "campaigns.json")
+	for registry, valid := range registries {
+		ioutil.WriteFile(fileName, []byte(registry), 0644)
+		_, err := campaign.Load(fileName)
+		if (err == nil) != valid {
+			t.Error("For", registry, "expected valid", valid, "got", err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/archive"
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/campaign"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/logging"
+	"github.com/iAmSomeone2/aacautoupdate/refund"
+	"github.com/iAmSomeone2/aacautoupdate/reward"
+	"github.com/iAmSomeone2/aacautoupdate/serve"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+	"github.com/iAmSomeone2/aacautoupdate/update"
+)
+
+// source is a patron export that is checked on every pass of the main loop,
+// along with the pipeline that publishes it.
+type source struct {
+	slug   string
+	url    string
+	pipe   *pipeline
+	start  time.Time
+	grace  time.Duration
+	frozen bool
+}
+
+// campaignSources builds a source for every campaign in the registry. Settings
+// a campaign leaves empty are taken from base and the default start and grace
+// period, and each campaign gets its own state directory and output path
+// unless it names one. A campaign with its own layout only uses its own
+// reservations. The overlay, payments, matching, recognition and telemetry
+// files are only ever taken from the campaign's own entry. Every campaign's
+// data is also served at /campaigns/{slug}/patron-data.
+func campaignSources(campaigns []*campaign.Campaign, base options, start time.Time, grace time.Duration, outDir, layoutFile, fallback string) []*source {
+	logger := logging.NewLogger()
+
+	stateDirs := campaignStateDirs(campaigns)
+	var sources []*source
+	for _, c := range campaigns {
+		opts := campaignOptions(c, base, stateDirs, outDir, layoutFile, fallback)
+
+		campaignStart, campaignGrace := start, grace
+		var err error
+		if c.Start != "" {
+			if campaignStart, err = parseDate(c.Start); err != nil {
+				logger.Fatalf("ERROR: campaign %s: %v\n", c.Slug, err)
+			}
+		}
+		if c.Grace != "" {
+			if campaignGrace, err = time.ParseDuration(c.Grace); err != nil {
+				logger.Fatalf("ERROR: campaign %s: %v\n", c.Slug, err)
+			}
+		}
+
+		serve.AddCampaign(c.Slug, opts.outputPath)
+		sources = append(sources, newSource(c.Slug, c.Source, opts, campaignStart, campaignGrace))
+	}
+	return sources
+}
+
+// campaignStateDirs returns the state directory of every campaign in the
+// registry, keyed by slug.
+func campaignStateDirs(campaigns []*campaign.Campaign) map[string]string {
+	stateDirs := make(map[string]string)
+	for _, c := range campaigns {
+		stateDirs[c.Slug] = c.StateDir
+		if c.StateDir == "" {
+			stateDirs[c.Slug] = path.Join(defaultStateDir(), "campaigns", c.Slug)
+		}
+	}
+	return stateDirs
+}
+
+// campaignOptions returns the pipeline options for c, with anything it leaves
+// empty taken from base.
+func campaignOptions(c *campaign.Campaign, base options, stateDirs map[string]string, outDir, layoutFile, fallback string) options {
+	logger := logging.NewLogger()
+
+	opts := base
+	opts.stateDir = stateDirs[c.Slug]
+	opts.outputPath = c.Output
+	if opts.outputPath == "" {
+		opts.outputPath = path.Join(outDir, c.Slug, outputFile)
+	}
+	if c.CellPrice > 0 {
+		opts.cellPrice = c.CellPrice
+	}
+	opts.overlay = c.Overlay
+	opts.payments = c.Payments
+	opts.telemetry = c.Telemetry
+	if c.Capacity > 0 {
+		opts.capacity = c.Capacity
+	}
+	if c.Layout != "" {
+		opts.layout, opts.reserved = loadLayout(c.Layout, c.Reservations, fallback, opts.telemetry)
+	} else if c.Reservations != "" {
+		opts.layout, opts.reserved = loadLayout(layoutFile, c.Reservations, fallback, opts.telemetry)
+	}
+	if c.Telemetry != "" && opts.layout == nil {
+		logger.Fatalf("ERROR: campaign %s: telemetry can only be used along with a layout.\n", c.Slug)
+	}
+	opts.matching = nil
+	if c.Matching != "" {
+		matching, err := data.LoadMatchingGifts(c.Matching)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts.matching = matching
+	}
+	opts.recognition = nil
+	if c.Recognition != "" {
+		recognition, err := data.LoadRecognition(c.Recognition)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts.recognition = recognition
+	}
+	if c.Goals != "" {
+		goal, err := data.LoadGoal(c.Goals)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts.goal = goal
+	}
+	if c.CarryFrom != "" {
+		opts.carryIn = path.Join(stateDirs[c.CarryFrom], carryoverFile)
+	}
+	if c.End != "" {
+		end, err := parseDate(c.End)
+		if err != nil {
+			logger.Fatalf("ERROR: campaign %s: %v\n", c.Slug, err)
+		}
+		opts.end = end
+	}
+	return opts
+}
+
+// newSource sets up a source, noting whether its campaign was already frozen
+// on an earlier run.
+func newSource(slug, url string, opts options, start time.Time, grace time.Duration) *source {
+	return &source{
+		slug:   slug,
+		url:    url,
+		pipe:   newPipeline(opts),
+		start:  start,
+		grace:  grace,
+		frozen: archive.Frozen(opts.stateDir),
+	}
+}
+
+// check downloads the source's export and publishes it if anything changed.
+// Nothing is downloaded before the campaign starts, and once the campaign has
+// ended and its grace period for late pledges is over it's frozen for good.
+func (src *source) check() {
+	logger := logging.NewLogger()
+	prefix := ""
+	if src.slug != "" {
+		prefix = "[" + src.slug + "] "
+	}
+
+	now := time.Now()
+	if src.frozen {
+		return
+	}
+	if !src.start.IsZero() && now.Before(src.start) {
+		logger.Printf("%sCampaign starts %s. Nothing to do yet.\n", prefix, src.start.Format("2006-01-02 15:04"))
+		return
+	}
+	end := src.pipe.opts.end
+	if !end.IsZero() && now.After(end.Add(src.grace)) {
+		if err := src.freeze(); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("%sCampaign closed. Final data archived to %s.\n", prefix, path.Join(src.pipe.opts.stateDir, archive.FileName))
+		return
+	}
+
+	fileName := update.CheckForUpdateIn(src.url, src.pipe.opts.stateDir)
+
+	// Decisions made by an operator need to be published even if the
+	// download hasn't changed, so reuse the cached copy.
+	if fileName == "" && src.pipe.stateChanged() {
+		logger.Println(prefix + "Operator changes found. Republishing cached data.")
+		fileName = path.Join(src.pipe.opts.stateDir, update.BaseFileName)
+	}
+
+	// If fileName is not empty, process the data in that file.
+	if fileName != "" {
+		log.Printf("%sDownloaded file located at: '%s'\n", prefix, fileName)
+		// Continue work to process the data.
+		if err := src.pipe.run(fileName); err != nil {
+			logger.Fatal(err)
+		} else {
+			logger.Printf("%sData written to %s\n", prefix, src.pipe.opts.outputPath)
+		}
+	} else {
+		logger.Printf("%sNothing to do. Will check again soon.\n", prefix)
+	}
+}
+
+// freeze publishes the campaign one last time, marked as final, and archives
+// the published data along with the state behind it.
+func (src *source) freeze() error {
+	stateDir := src.pipe.opts.stateDir
+	cached := path.Join(stateDir, update.BaseFileName)
+	if fileName := update.CheckForUpdateIn(src.url, stateDir); fileName != "" {
+		cached = fileName
+	}
+
+	src.pipe.final = true
+	if _, err := os.Stat(cached); err == nil {
+		if err := src.pipe.run(cached); err != nil {
+			return err
+		}
+	}
+
+	files := []string{
+		src.pipe.opts.outputPath,
+		path.Join(path.Dir(src.pipe.opts.outputPath), stats.FileName),
+		path.Join(stateDir, stats.HistoryFile),
+		path.Join(stateDir, privateFile),
+		path.Join(stateDir, allocationsFile),
+		path.Join(stateDir, transfersFile),
+		path.Join(stateDir, carryoverFile),
+		path.Join(stateDir, alertsFile),
+		path.Join(stateDir, refund.SnapshotFile),
+		path.Join(stateDir, reward.StateFile),
+		path.Join(stateDir, audit.FileName),
+	}
+	manifest, err := archive.Create(stateDir, src.slug, src.pipe.opts.signingKey, files)
+	if err != nil {
+		return err
+	}
+	auditLog := audit.Open(path.Join(stateDir, audit.FileName))
+	if err := auditLog.Record("freeze", archive.FileName, fmt.Sprintf("%d file(s)", len(manifest.Files))); err != nil {
+		return err
+	}
+
+	src.frozen = true
+	return nil
+}
+
+// parseDate reads a campaign date, with or without a time of day, in local
+// time.
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DD HH:MM", value)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// runCells implements the "cells" subcommand, which lets an operator move
+// adoptions to other positions when cells are replaced or the array is
+// rebuilt. The moves take effect on the next run.
+func runCells(args []string) {
+	flags := flag.NewFlagSet("cells", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the cell allocations.")
+	layoutPtr := flags.String("layout", "", "The layout the adoptions are moving onto. Every target must be an open cell on it.")
+	transferPtr := flags.String("transfer", "", "Move a single adoption, given as \"old:new\", e.g. \"112:205\".")
+	remapPtr := flags.String("remap", "", "A file of \"old,new\" cell id pairs to move every adoption at once.")
+	reasonPtr := flags.String("reason", "", "Why the adoptions are moving, kept in the history.")
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	allocations := path.Join(*statePtr, allocationsFile)
+	transfers := path.Join(*statePtr, transfersFile)
+	assignments := make(map[string][]int)
+	if err := readJSON(allocations, &assignments); err != nil {
+		fail(err)
+	}
+	var history []data.Move
+	if err := readJSON(transfers, &history); err != nil {
+		fail(err)
+	}
+
+	if *transferPtr == "" && *remapPtr == "" {
+		if len(history) == 0 {
+			fmt.Println("No adoptions have been moved.")
+		}
+		for _, move := range history {
+			fmt.Printf("%s  cell %-4d -> cell %-4d %s\n", move.Time.Format("2006-01-02 15:04"), move.From, move.To, move.Reason)
+		}
+		return
+	}
+
+	// Adoptions only have positions to move between on an array with a
+	// layout.
+	if *layoutPtr == "" {
+		fail(fmt.Errorf("-layout is required to move adoptions"))
+	}
+	layout, err := data.LoadLayout(*layoutPtr)
+	if err != nil {
+		fail(err)
+	}
+
+	var moves []data.Move
+	if *transferPtr != "" {
+		parts := strings.Split(*transferPtr, ":")
+		from, errFrom := strconv.Atoi(strings.TrimSpace(parts[0]))
+		var to int
+		errTo := fmt.Errorf("missing new cell")
+		if len(parts) == 2 {
+			to, errTo = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+		if errFrom != nil || errTo != nil {
+			fail(fmt.Errorf("-transfer must be given as \"old:new\", not %q", *transferPtr))
+		}
+		move, err := data.Transfer(assignments, from, to, layout, *reasonPtr)
+		if err != nil {
+			fail(err)
+		}
+		moves = append(moves, move)
+	}
+	if *remapPtr != "" {
+		mapping, err := data.LoadMapping(*remapPtr)
+		if err != nil {
+			fail(err)
+		}
+		remapped, err := data.Remap(assignments, mapping, layout, *reasonPtr)
+		if err != nil {
+			fail(err)
+		}
+		moves = append(moves, remapped...)
+	}
+
+	log := audit.Open(path.Join(*statePtr, audit.FileName))
+	for _, move := range moves {
+		detail := fmt.Sprintf("cell %d -> cell %d", move.From, move.To)
+		if err := log.Record("cell-transfer", move.Owner, detail); err != nil {
+			fail(err)
+		}
+	}
+	history = append(history, moves...)
+
+	if err := writeJSON(allocations, assignments); err != nil {
+		fail(err)
+	}
+	if err := writeJSON(transfers, history); err != nil {
+		fail(err)
+	}
+	fmt.Printf("Moved %d adoption(s).\n", len(moves))
+}
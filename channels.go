@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+)
+
+// runChannels implements the "channels" subcommand, which reports the
+// donations and cells that came in through each outreach channel. It takes the
+// updater's flags, so the pledges are prepared the same way as for each run.
+func runChannels(args []string) {
+	flags := flag.NewFlagSet("channels", flag.ExitOnError)
+	outPtr := flags.String("out", "", "A CSV file to write each channel's pledges per day to.")
+	settings := addReportFlags(flags)
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cellList, opts, err := settings.cellList()
+	if err != nil {
+		fail(err)
+	}
+	channels := stats.ByChannel(cellList.Pledges(), opts.cellPrice)
+
+	fmt.Printf("%-40s %8s %10s %8s %7s  %-10s %-10s\n", "Channel", "Pledges", "Amount", "Cells", "Share", "First", "Last")
+	for _, channel := range channels {
+		fmt.Printf("%-40s %8d %10d %8.1f %6.1f%%  %-10s %-10s\n", channel.Name, channel.Pledges, channel.Amount,
+			channel.Cells, channel.Share, channel.First.Format("2006-01-02"), channel.Last.Format("2006-01-02"))
+	}
+
+	if *outPtr == "" {
+		return
+	}
+	if err := os.MkdirAll(path.Dir(*outPtr), os.ModeDir|os.ModePerm); err != nil {
+		fail(err)
+	}
+	file, err := os.Create(*outPtr)
+	if err != nil {
+		fail(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"channel", "date", "pledges", "amount", "cells", "running_pledges", "running_amount"})
+	for _, channel := range channels {
+		for _, day := range channel.Daily {
+			writer.Write([]string{
+				channel.Name,
+				day.Date,
+				strconv.Itoa(day.Pledges),
+				strconv.Itoa(day.Amount),
+				strconv.FormatFloat(float64(day.Amount)/float64(opts.cellPrice), 'f', 2, 64),
+				strconv.Itoa(day.RunningPledges),
+				strconv.Itoa(day.RunningAmount),
+			})
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fail(err)
+	}
+	fmt.Printf("Daily breakdown written to %s.\n", *outPtr)
+}
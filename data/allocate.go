@@ -0,0 +1,413 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OverflowMode decides how adoptions beyond the array's capacity are shown.
+type OverflowMode string
+
+// BlockMode decides how the cells of a multi-cell donor are grouped together
+// on the array.
+type BlockMode string
+
+const (
+	// OverflowWaitlist queues extra adoptions in order so they move onto the
+	// array as cells free up.
+	OverflowWaitlist OverflowMode = "waitlist"
+	// OverflowHonorary lists extra adoptions in an honorary section that isn't
+	// tied to the physical array.
+	OverflowHonorary OverflowMode = "honorary"
+
+	// BlockScattered hands out cells one at a time in the fill order.
+	BlockScattered BlockMode = "scattered"
+	// BlockRect places a donor's cells as a compact rectangle anywhere on
+	// the array.
+	BlockRect BlockMode = "rect"
+	// BlockModule places a donor's cells as a compact rectangle inside a
+	// single module.
+	BlockModule BlockMode = "module"
+
+	// CellAdopted is the status of a cell placed on the array.
+	CellAdopted string = "adopted"
+	// CellWaitlisted is the status of a cell waiting for space on the array.
+	CellWaitlisted string = "waitlisted"
+	// CellHonorary is the status of a cell in the honorary section.
+	CellHonorary string = "honorary"
+	// CellReserved is the status of a position held for a Reservation that
+	// hasn't been claimed yet.
+	CellReserved string = "reserved"
+)
+
+// ParseOverflowMode converts an overflow mode name into an OverflowMode.
+func ParseOverflowMode(name string) (OverflowMode, error) {
+	switch mode := OverflowMode(strings.ToLower(name)); mode {
+	case OverflowWaitlist, OverflowHonorary:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown overflow mode %q", name)
+}
+
+// ParseBlockMode converts a block mode name into a BlockMode.
+func ParseBlockMode(name string) (BlockMode, error) {
+	switch mode := BlockMode(strings.ToLower(name)); mode {
+	case BlockScattered, BlockRect, BlockModule:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown block mode %q", name)
+}
+
+// CellOptions controls how NewCellList places cells on the physical array.
+type CellOptions struct {
+	// Layout is the geometry of the array. If it's nil, cells are only
+	// numbered and carry no position.
+	Layout *Layout
+	// Fill is the order free positions are handed out in.
+	Fill FillOrder
+	// Capacity is the most cells that may be adopted. If it's 0, the number
+	// of open positions on the Layout is used, and if there's no Layout the
+	// array is unlimited.
+	Capacity int
+	// Overflow decides what happens to adoptions beyond Capacity.
+	Overflow OverflowMode
+	// Block decides how a donor's cells are grouped on the Layout.
+	Block BlockMode
+	// NoScatter stops a donor's cells from being scattered when no block
+	// fits. Those cells go to the overflow until a block frees up.
+	NoScatter bool
+	// Assignments maps each owner to the position ids they were given on
+	// earlier runs. Owners keep those positions while they're still valid,
+	// and the map is updated in place with the result of this run.
+	Assignments map[string][]int
+	// Reservations hold positions back from automatic assignment. They're
+	// only used when there is a Layout.
+	Reservations []*Reservation
+	// FallbackRegion is where donors go when their placement request can't
+	// be met. If it's empty, they're placed like everyone else.
+	FallbackRegion string
+	// Credit decides what happens to pledges that don't add up to a whole
+	// cell.
+	Credit CreditPolicy
+	// MatchingGifts are sponsors' offers to match pledges. They're worked out
+	// before any cells are made.
+	MatchingGifts []*MatchingGift
+	// Moves is the history of adoptions transferred between positions. It's
+	// only used to show where a cell was originally.
+	Moves []Move
+}
+
+// capacity works out the effective capacity of the array. A configured
+// capacity can only shrink what the layout allows, never grow it.
+func (opts *CellOptions) capacity() int {
+	capacity := opts.Capacity
+	if opts.Layout != nil {
+		open := len(opts.Layout.Order(opts.Fill))
+		if capacity == 0 || capacity > open {
+			capacity = open
+		}
+	}
+	return capacity
+}
+
+// allocator hands out free positions on the layout.
+type allocator struct {
+	opts      *CellOptions
+	order     []*Position
+	free      map[int]bool
+	remaining int
+}
+
+// newAllocator returns an allocator with every open position free.
+func newAllocator(opts *CellOptions, capacity int) *allocator {
+	alloc := &allocator{
+		opts:      opts,
+		order:     opts.Layout.Order(opts.Fill),
+		free:      make(map[int]bool),
+		remaining: capacity,
+	}
+	for _, pos := range alloc.order {
+		alloc.free[pos.ID] = true
+	}
+	return alloc
+}
+
+// take marks pos as used.
+func (alloc *allocator) take(pos *Position) {
+	delete(alloc.free, pos.ID)
+	alloc.remaining--
+}
+
+// allocate finds up to n positions for one owner using the block mode. Fewer
+// than n positions are returned once the array or its capacity runs out.
+func (alloc *allocator) allocate(n int) []*Position {
+	if n > alloc.remaining {
+		n = alloc.remaining
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	var block []*Position
+	switch alloc.opts.Block {
+	case BlockRect:
+		block = alloc.findBlock(n, false, nil)
+	case BlockModule:
+		block = alloc.findBlock(n, true, nil)
+	default:
+		return alloc.scatter(n)
+	}
+
+	if block == nil && n > 1 && alloc.opts.NoScatter {
+		return nil
+	}
+	if block == nil {
+		return alloc.scatter(n)
+	}
+	for _, pos := range block {
+		alloc.take(pos)
+	}
+	return block
+}
+
+// scatter takes the next n free positions in the fill order.
+func (alloc *allocator) scatter(n int) []*Position {
+	var positions []*Position
+	for _, pos := range alloc.order {
+		if len(positions) == n {
+			break
+		}
+		if alloc.free[pos.ID] {
+			positions = append(positions, pos)
+			alloc.take(pos)
+		}
+	}
+	return positions
+}
+
+// blockShape is the height and width of a candidate block.
+type blockShape struct {
+	rows, cols int
+}
+
+// findBlock looks for n free positions forming a compact rectangle, filled
+// row-major so that only the last row may be short. The squarest shapes are
+// tried first, and for each shape the anchors are tried in the fill order.
+// Nil is returned if no block fits. If allowed isn't nil, only positions in it may be used.
+func (alloc *allocator) findBlock(n int, sameModule bool, allowed map[int]bool) []*Position {
+	var shapes []blockShape
+	for rows := 1; rows <= n; rows++ {
+		cols := (n + rows - 1) / rows
+		if rows*cols-n < cols {
+			shapes = append(shapes, blockShape{rows: rows, cols: cols})
+		}
+	}
+	sort.SliceStable(shapes, func(i, j int) bool {
+		a, b := shapes[i], shapes[j]
+		if maxInt(a.rows, a.cols) != maxInt(b.rows, b.cols) {
+			return maxInt(a.rows, a.cols) < maxInt(b.rows, b.cols)
+		}
+		// Wide blocks read better on the graphic than tall ones.
+		return a.cols > b.cols
+	})
+
+	for _, shape := range shapes {
+		for _, anchor := range alloc.order {
+			if !alloc.free[anchor.ID] {
+				continue
+			}
+			if block := alloc.blockAt(anchor, shape, n, sameModule, allowed); block != nil {
+				return block
+			}
+		}
+	}
+	return nil
+}
+
+// blockAt returns the n positions of shape with anchor in its top-left
+// corner, or nil if any of them are missing, taken, not allowed or in the
+// wrong module.
+func (alloc *allocator) blockAt(anchor *Position, shape blockShape, n int, sameModule bool, allowed map[int]bool) []*Position {
+	block := make([]*Position, 0, n)
+	for i := 0; i < n; i++ {
+		pos, ok := alloc.opts.Layout.At(anchor.Row+i/shape.cols, anchor.Col+i%shape.cols)
+		if !ok || !alloc.free[pos.ID] {
+			return nil
+		}
+		if allowed != nil && !allowed[pos.ID] {
+			return nil
+		}
+		if sameModule && pos.moduleIdx != anchor.moduleIdx {
+			return nil
+		}
+		block = append(block, pos)
+	}
+	return block
+}
+
+// placeCells assigns each cell a position on the layout and splits off any
+// cells beyond capacity into the overflow, in the order they were adopted.
+// Reserved positions go to the patron they're held for, or are returned as
+// placeholder cells if that patron hasn't pledged yet. Owners keep the
+// positions they had on earlier runs, and new adoptions are placed according
+// to the block mode. Cells take the id of their physical position so that the
+// published ids match the array.
+func placeCells(cells []*Cell, opts *CellOptions, capacity int) ([]*Cell, []*Cell, []*Cell) {
+	var placed, overflow, reserved []*Cell
+
+	if opts.Layout == nil {
+		placed = cells
+		if capacity > 0 && len(cells) > capacity {
+			placed, overflow = cells[:capacity], cells[capacity:]
+		}
+		markOverflow(overflow, opts.Overflow)
+		return placed, overflow, nil
+	}
+
+	// Group the cells by owner, keeping the order they were adopted in.
+	var owners []string
+	byOwner := make(map[string][]*Cell)
+	for _, cell := range cells {
+		if _, seen := byOwner[cell.owner]; !seen {
+			owners = append(owners, cell.owner)
+		}
+		byOwner[cell.owner] = append(byOwner[cell.owner], cell)
+	}
+
+	alloc := newAllocator(opts, capacity)
+	assigned := make(map[*Cell]bool)
+
+	// Reserved positions come out of the pool before anything else. A
+	// position someone already adopted stays with them, though.
+	heldBy := make(map[int]string)
+	for owner, ids := range opts.Assignments {
+		for _, id := range ids {
+			heldBy[id] = owner
+		}
+	}
+	for _, reservation := range opts.Reservations {
+		var claimants []*Cell
+		for _, cell := range cells {
+			if !assigned[cell] && reservation.claims(cell) {
+				claimants = append(claimants, cell)
+			}
+		}
+
+		for _, pos := range reservation.positions {
+			owner, held := heldBy[pos.ID]
+			if !alloc.free[pos.ID] || alloc.remaining == 0 {
+				continue
+			}
+			if held && (len(claimants) == 0 || claimants[0].owner != owner) {
+				continue
+			}
+			alloc.take(pos)
+
+			if len(claimants) > 0 {
+				claimants[0].pos = pos
+				claimants[0].label = reservation.Label
+				assigned[claimants[0]] = true
+				claimants = claimants[1:]
+				continue
+			}
+			reserved = append(reserved, &Cell{
+				id:     pos.ID,
+				pos:    pos,
+				status: CellReserved,
+				label:  reservation.Label,
+			})
+		}
+	}
+
+	// Existing owners get their old positions back first so that newer
+	// adoptions can't take them.
+	for _, owner := range owners {
+		waiting := unassigned(byOwner[owner], assigned)
+		for _, id := range opts.Assignments[owner] {
+			if len(waiting) == 0 || alloc.remaining == 0 {
+				break
+			}
+			if !alloc.free[id] {
+				continue
+			}
+			pos, _ := opts.Layout.Lookup(id)
+			alloc.take(pos)
+			waiting[0].pos = pos
+			assigned[waiting[0]] = true
+			waiting = waiting[1:]
+		}
+	}
+
+	// Placement requests are handled next, first come first served.
+	for _, owner := range owners {
+		waiting := unassigned(byOwner[owner], assigned)
+		if patron := requester(waiting); patron != nil && len(waiting) == len(byOwner[owner]) {
+			alloc.placePreferred(waiting, patron, cells, assigned)
+		}
+	}
+
+	// Everything else is allocated in adoption order.
+	for _, owner := range owners {
+		waiting := unassigned(byOwner[owner], assigned)
+		for i, pos := range alloc.allocate(len(waiting)) {
+			waiting[i].pos = pos
+			assigned[waiting[i]] = true
+		}
+	}
+
+	if opts.Assignments != nil {
+		for owner := range opts.Assignments {
+			delete(opts.Assignments, owner)
+		}
+	}
+	for _, cell := range cells {
+		if !assigned[cell] {
+			overflow = append(overflow, cell)
+			continue
+		}
+		cell.id = cell.pos.ID
+		cell.history = History(opts.Moves, cell.owner, cell.pos.ID)
+		placed = append(placed, cell)
+		if opts.Assignments != nil {
+			opts.Assignments[cell.owner] = append(opts.Assignments[cell.owner], cell.pos.ID)
+		}
+	}
+
+	for _, owner := range owners {
+		if requester(byOwner[owner]) != nil {
+			result := placementResult(byOwner[owner], cells, opts)
+			for _, cell := range byOwner[owner] {
+				cell.placement = result
+			}
+		}
+	}
+
+	markOverflow(overflow, opts.Overflow)
+	return placed, overflow, reserved
+}
+
+// unassigned returns the cells that haven't been given a position yet.
+func unassigned(cells []*Cell, assigned map[*Cell]bool) []*Cell {
+	var waiting []*Cell
+	for _, cell := range cells {
+		if !assigned[cell] {
+			waiting = append(waiting, cell)
+		}
+	}
+	return waiting
+}
+
+// markOverflow labels cells that didn't fit on the array and numbers them in
+// the order they're waiting. A cell's payment status is left as it is, so an
+// unpaid cell is still shown as unpaid in the overflow.
+func markOverflow(overflow []*Cell, mode OverflowMode) {
+	for i, cell := range overflow {
+		cell.id = 0
+		cell.status = CellWaitlisted
+		if mode == OverflowHonorary {
+			cell.status = CellHonorary
+		}
+		cell.queuePos = i + 1
+	}
+}
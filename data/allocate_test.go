@@ -0,0 +1,145 @@
+package data_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+const testLayout string = `{
+	"modules": [
+		{"name": "A", "string": "S1", "row": 0, "col": 0, "rows": 3, "cols": 4},
+		{"name": "B", "string": "S1", "row": 0, "col": 4, "rows": 3, "cols": 4}
+	]
+}`
+
+// publishedCells pulls the id and position of every placed cell out of the
+// published JSON.
+func publishedCells(t *testing.T, list *data.CellList) []map[string]interface{} {
+	var published struct {
+		Cells []map[string]interface{} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	return published.Cells
+}
+
+func TestBlockAllocationIsCompactAndStable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(testLayout), 0644)
+
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignments := make(map[string][]int)
+	opts := &data.CellOptions{
+		Layout:      layout,
+		Fill:        data.FillRowMajor,
+		Block:       data.BlockModule,
+		Assignments: assignments,
+	}
+
+	small := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+	big := data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 200)
+	first := publishedCells(t, data.NewCellList(data.NewPatronList([]*data.Patron{big, small}), opts))
+
+	// John's four cells should form a 2x2 block inside a single module.
+	rows := make(map[float64]int)
+	for _, cell := range first {
+		pos := cell["position"].(map[string]interface{})
+		named := cell["named_adoptees"].([]interface{})[0].(map[string]interface{})
+		if named["name"] == "John Doe" {
+			if pos["module"] != "A" {
+				t.Error("Block crossed into module", pos["module"])
+			}
+			rows[pos["row"].(float64)]++
+		}
+	}
+	if len(rows) != 2 {
+		t.Error(
+			"For", "block rows",
+			"expected", 2,
+			"got", len(rows),
+		)
+	}
+
+	// A new donor adopting earlier in the export must not move anyone.
+	early := data.NewPatron(3, "2019-03-01 09:00:00", false, "Ann", "Lee", 50)
+	small = data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+	big = data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 200)
+	second := publishedCells(t, data.NewCellList(data.NewPatronList([]*data.Patron{big, small, early}), opts))
+
+	before := make(map[float64]bool)
+	for _, cell := range first {
+		before[cell["id"].(float64)] = true
+	}
+	kept := 0
+	for _, cell := range second {
+		if before[cell["id"].(float64)] {
+			kept++
+		}
+	}
+	if kept != len(first) {
+		t.Error(
+			"For", "stable allocation",
+			"expected", len(first),
+			"got", kept,
+		)
+	}
+}
+
+func TestPairingIsStable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(testLayout), 0644)
+
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func() string {
+		var patrons []*data.Patron
+		for i, name := range []string{"Ann", "Bob", "Cal", "Dee", "Eve", "Fay", "Gus"} {
+			pledgeTime := fmt.Sprintf("2019-04-%02d 10:00:00", i+1)
+			patrons = append(patrons, data.NewPatron(i+1, pledgeTime, false, name, "Lee", 25))
+		}
+		patrons = append(patrons, data.NewPatron(8, "2019-04-10 10:00:00", false, "John", "Doe", 100))
+		opts := &data.CellOptions{
+			Layout:      layout,
+			Fill:        data.FillRowMajor,
+			Block:       data.BlockModule,
+			Assignments: make(map[string][]int),
+		}
+		cells, err := json.Marshal(publishedCells(t, data.NewCellList(data.NewPatronList(patrons), opts)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(cells)
+	}
+
+	// Building the same list again must give every donor the same cells.
+	first := build()
+	for i := 0; i < 20; i++ {
+		if cells := build(); cells != first {
+			t.Fatal("For", "build", i, "expected", first, "got", cells)
+		}
+	}
+}
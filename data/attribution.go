@@ -0,0 +1,69 @@
+package data
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Unattributed is the channel of a pledge whose export row says nothing about
+// how the donor found the campaign.
+const Unattributed string = "unattributed"
+
+// SelfReported is the channel published in place of whatever a donor typed
+// into the referral or survey columns, since those answers aren't moderated.
+const SelfReported string = "self-reported"
+
+// attributionHeaders maps each part of a pledge's attribution to the column
+// headings that may hold it. UTM parameters come from tracked links, while the
+// referral and survey columns are filled in by the donor.
+var attributionHeaders = map[string][]string{
+	"utm_source":   {"utm_source", "utm source"},
+	"utm_medium":   {"utm_medium", "utm medium"},
+	"utm_campaign": {"utm_campaign", "utm campaign"},
+	"referral":     {"referral", "referrer", "referred by", "referral source", "lead source"},
+	"heard":        {"how did you hear", "how did you hear about us", "how did you hear about us?", "how did you find us"},
+}
+
+var channelSpacing = regexp.MustCompile(`\s+`)
+
+// Channel works out the outreach channel of a pledge from its attribution
+// columns. Tracked links are the most reliable, so the UTM source, medium and
+// campaign are used when there is a source. Otherwise the referral column is
+// used, and then the donor's answer to how they heard about the campaign.
+func Channel(attribution map[string]string) string {
+	clean := func(attr string) string {
+		value := strings.ToLower(strings.TrimSpace(attribution[attr]))
+		return channelSpacing.ReplaceAllString(value, " ")
+	}
+
+	if source := clean("utm_source"); source != "" {
+		parts := []string{source}
+		for _, attr := range []string{"utm_medium", "utm_campaign"} {
+			if value := clean(attr); value != "" {
+				parts = append(parts, value)
+			}
+		}
+		return strings.Join(parts, " / ")
+	}
+	for _, attr := range []string{"referral", "heard"} {
+		if value := clean(attr); value != "" {
+			return value
+		}
+	}
+	return Unattributed
+}
+
+// Tracked reports whether Channel takes the channel from a tracked link rather
+// than from something the donor wrote.
+func Tracked(attribution map[string]string) bool {
+	return strings.TrimSpace(attribution["utm_source"]) != ""
+}
+
+// SetChannel records the outreach channel of every pledge the Patron holds,
+// and whether it came from a tracked link.
+func (patron *Patron) SetChannel(channel string, tracked bool) {
+	for i := range patron.pledges {
+		patron.pledges[i].Channel = channel
+		patron.pledges[i].Tracked = tracked
+	}
+}
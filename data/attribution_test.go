@@ -0,0 +1,33 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestChannel(t *testing.T) {
+	tests := []struct {
+		attribution map[string]string
+		channel     string
+	}{
+		{map[string]string{"utm_source": "Facebook", "utm_medium": "social", "utm_campaign": "Spring  Drive", "heard": "a friend"}, "facebook / social / spring drive"},
+		{map[string]string{"referral": "", "heard": " County Fair "}, "county fair"},
+		{map[string]string{"referral": "Jane Smith", "heard": "a friend"}, "jane smith"},
+		{map[string]string{}, data.Unattributed},
+	}
+	for _, test := range tests {
+		if channel := data.Channel(test.attribution); channel != test.channel {
+			t.Error("For", test.attribution, "expected", test.channel, "got", channel)
+		}
+	}
+}
+
+func TestTracked(t *testing.T) {
+	if !data.Tracked(map[string]string{"utm_source": "Facebook", "heard": "a friend"}) {
+		t.Error("For", "utm_source", "expected", true, "got", false)
+	}
+	if data.Tracked(map[string]string{"utm_source": " ", "referral": "Jane Smith"}) {
+		t.Error("For", "referral", "expected", false, "got", true)
+	}
+}
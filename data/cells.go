@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/iAmSomeone2/aacautoupdate/logging"
@@ -17,8 +19,19 @@ import (
 type CellList struct {
 	patrons          *PatronList
 	cells            []*Cell
+	overflow         []*Cell
+	reserved         []*Cell
+	capacity         int
+	overflowMode     OverflowMode
 	credit           float32
+	creditPolicy     CreditPolicy
 	remainingPatrons map[int]*Patron
+	layout           *Layout
+	hasEnergy        bool
+	goal             *Goal
+	recognition      *Recognition
+	frozenAt         time.Time
+	reached          map[string]time.Time
 	logger           *logging.Logger
 	updateTime       time.Time
 }
@@ -27,16 +40,100 @@ type CellList struct {
 // is the int value referencing the associated cell in the array. The adoptee value
 // is a slice of Patron pointers because it is possible for a cell to be owned by
 // any number of patrons as long as their contributions equal out to the price per cell.
+// The adopteeIDs always point at the patrons who paid, while named holds the names
+// shown on the cell, which differ from the payers for gift adoptions.
 type Cell struct {
-	id         int
-	adopteeIDs []int
-	logger     *logging.Logger
+	id          int
+	pos         *Position
+	status      string
+	queuePos    int
+	owner       string
+	label       string
+	placement   string
+	fill        float32
+	payment     string
+	energy      float64
+	completion  time.Time
+	matchedBy   []string
+	matchingFor []int
+	history     []Move
+	adoptees    []*Patron
+	adopteeIDs  []int
+	named       []namedAdoptee
+	dedications []dedication
+	logger      *logging.Logger
+}
+
+// namedAdoptee is the name shown on a cell for one of its payers. For a gift
+// adoption, name is the honoree and giftedBy is the payer, if they allow it.
+type namedAdoptee struct {
+	patronID int
+	name     string
+	giftedBy string
+}
+
+// dedication is a message an adoptee attached to their cell.
+type dedication struct {
+	patronID int
+	text     string
+	state    string
+}
+
+// newCell creates a Cell owned by the given adoptees and collects any
+// dedication messages they left.
+func newCell(id int, adoptees []*Patron, logger *logging.Logger) *Cell {
+	cell := &Cell{id: id, status: CellAdopted, fill: 1, adoptees: adoptees, logger: logger}
+	var keys []string
+	for _, adoptee := range adoptees {
+		keys = append(keys, adoptee.Key())
+		cell.adopteeIDs = append(cell.adopteeIDs, adoptee.id)
+		cell.named = append(cell.named, adoptee.namedAdoptee())
+		cell.matchingFor = append(cell.matchingFor, adoptee.sponsorFor...)
+		for _, credit := range adoptee.matches {
+			if credit.applied {
+				cell.matchedBy = append(cell.matchedBy, credit.sponsor)
+			}
+		}
+		if adoptee.message != "" && adoptee.msgState != MessageRejected {
+			cell.dedications = append(cell.dedications, dedication{
+				patronID: adoptee.id,
+				text:     adoptee.message,
+				state:    adoptee.msgState,
+			})
+		}
+	}
+	sort.Strings(keys)
+	cell.owner = strings.Join(keys, "+")
+	return cell
+}
+
+// namedAdoptee works out the name to show on a cell for the Patron. The payer's
+// and honoree's privacy settings are applied independently.
+func (patron *Patron) namedAdoptee() namedAdoptee {
+	named := namedAdoptee{patronID: patron.id, name: patron.Name()}
+	if patron.gift == nil {
+		return named
+	}
+
+	named.name = patron.gift.DisplayName()
+	if !patron.gift.hideGiver && !patron.anonymous {
+		named.giftedBy = patron.Name()
+	}
+	return named
 }
 
 // NewCellList returns a pointer to a newly created CellList object. The PatronList is
 // placed directly into the object. The Cell pointer slice is constructed based on the
-// contents of the PatronList.
-func NewCellList(list *PatronList) *CellList {
+// contents of the PatronList. If opts is nil, cells are numbered in the order they're
+// adopted without any physical position.
+func NewCellList(list *PatronList, opts *CellOptions) *CellList {
+	if opts == nil {
+		opts = &CellOptions{}
+	}
+
+	// Sponsors' matching funds either grow the donors' cell amounts or become
+	// cells of their own.
+	list = list.applyMatchingGifts(opts.MatchingGifts)
 
 	// For each Patron in the PatronList, construct a Cell and determine which patrons are the adoptees.
 	creditPatrons := make(map[int]*Patron)
@@ -47,11 +144,27 @@ func NewCellList(list *PatronList) *CellList {
 
 	for _, patron := range list.patrons {
 		// This conversion will chop off any decimal values.
+		// Cells beyond what has actually been collected are pending.
+		collected := patron.collectedCells()
 		for i := 0; i < int(patron.cellAmt); i++ {
-			cells = append(cells, &Cell{id: cellsIdx, adopteeIDs: []int{patron.id}, logger: logger})
+			cell := newCell(cellsIdx, []*Patron{patron}, logger)
+			if i >= collected {
+				cell.setPending()
+			}
+			cells = append(cells, cell)
 			cellsIdx++
 		}
 
+		// Installments toward a recurring donor's next cell are shown as in
+		// progress instead of going into the credit pool.
+		if patron.subscription != "" {
+			if fill := patron.cellAmt - float32(int(patron.cellAmt)); fill > 0 {
+				cells = append(cells, newInProgressCell(cellsIdx, patron, fill, logger))
+				cellsIdx++
+			}
+			continue
+		}
+
 		// Throw any patron that hasn't paid enough for a cell into the creditIDs list
 		if patron.cellAmt < 1 {
 			creditPatrons[patron.id] = patron
@@ -61,62 +174,99 @@ func NewCellList(list *PatronList) *CellList {
 		// If credit is >= 1 we should try to pair the extra patrons.
 		if credit >= 1 {
 			groups, remaining := groupPatrons(creditPatrons)
-			// Create any new cells from the resulting groups
-			if _, hasZero := groups[0]; !hasZero {
-				for _, group := range groups {
-					cells = append(cells, &Cell{id: cellsIdx, adopteeIDs: group, logger: logger})
-					cellsIdx++
+			// Create any new cells from the resulting groups, in the order
+			// they were paired.
+			for i := 1; i <= len(groups); i++ {
+				var adoptees []*Patron
+				for _, id := range groups[i] {
+					adoptees = append(adoptees, creditPatrons[id])
 				}
-				creditPatrons = remaining // Go won't let me assign this at the function call for some reason.
+				cell := newCell(cellsIdx, adoptees, logger)
+				markPending(cell)
+				cells = append(cells, cell)
+				cellsIdx++
+			}
+			creditPatrons = remaining // Go won't let me assign this at the function call for some reason.
 
-				// Here we update the remaining credit
-				var newCredit float32
-				for _, creditPatron := range creditPatrons {
-					newCredit += creditPatron.cellAmt
-				}
-				credit = newCredit
+			// Here we update the remaining credit
+			var newCredit float32
+			for _, creditPatron := range creditPatrons {
+				newCredit += creditPatron.cellAmt
 			}
+			credit = newCredit
+		}
+	}
+
+	// Whatever couldn't be paired up is handled by the credit policy.
+	community, creditPatrons, credit := communityCells(creditPatrons, opts.Credit, cellsIdx, logger)
+	var partial []*Cell
+	for _, cell := range community {
+		if cell.partial() {
+			partial = append(partial, cell)
+		} else {
+			cells = append(cells, cell)
+		}
+	}
+
+	capacity := opts.capacity()
+	cells, overflow, reserved := placeCells(cells, opts, capacity)
+	for _, cell := range reserved {
+		cell.logger = logger
+	}
+	for _, cell := range partial {
+		if opts.Layout != nil {
+			cell.id = 0
 		}
+		cells = append(cells, cell)
 	}
 
 	return &CellList{
 		patrons:          list,
 		cells:            cells,
+		overflow:         overflow,
+		reserved:         reserved,
+		capacity:         capacity,
+		overflowMode:     opts.Overflow,
 		credit:           credit,
+		creditPolicy:     opts.Credit,
 		remainingPatrons: creditPatrons,
+		layout:           opts.Layout,
 		logger:           logger,
 		updateTime:       time.Now(),
 	}
 }
 
 // groupPatrons takes in a list of Patrons and groups them into a map if they can be put together to equal the
-// value of a single cell. Any leftover Patrons will have their IDs returned separately.
+// value of a single cell. Any leftover Patrons will have their IDs returned separately. Patrons are paired in ID
+// order and the groups are numbered from 1, so the same patrons always end up in the same groups.
 func groupPatrons(patronMap map[int]*Patron) (map[int][]int, map[int]*Patron) {
 	groups := make(map[int][]int)
 	remains := make(map[int]*Patron)
 	paired := make(map[*Patron]bool)
 
+	ids := make([]int, 0, len(patronMap))
+	for id := range patronMap {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
 	count := 0
-	for _, iPatron := range patronMap {
+	for i, iID := range ids {
 		// First, we should pair up anyone who has donated half of the value of a cell.
-		if !paired[iPatron] {
-			var group []int
-			if iPatron.cellAmt == 0.5 {
-				group = append(group, iPatron.id)
-				//Check the remainder of the patronMap
-				for _, jPatron := range patronMap {
-					if !paired[jPatron] && jPatron != iPatron {
-						if jPatron.cellAmt == 0.5 {
-							group = append(group, jPatron.id)
-							paired[iPatron] = true
-							paired[jPatron] = true
-							count++
-							break
-						}
-					}
-				}
+		iPatron := patronMap[iID]
+		if paired[iPatron] || iPatron.cellAmt != 0.5 {
+			continue
+		}
+		//Check the remainder of the patronMap
+		for _, jID := range ids[i+1:] {
+			jPatron := patronMap[jID]
+			if !paired[jPatron] && jPatron.cellAmt == 0.5 {
+				paired[iPatron] = true
+				paired[jPatron] = true
+				count++
+				groups[count] = []int{iPatron.id, jPatron.id}
+				break
 			}
-			groups[count] = group
 		}
 	}
 
@@ -141,18 +291,193 @@ func (cell Cell) MarshalJSON() ([]byte, error) {
 	}
 	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "id", string(idJSON)))
 
+	// status field
+	statusJSON, err := json.Marshal(cell.status)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "status", string(statusJSON)))
+
+	// payment field, set while the money behind the cell hasn't been
+	// collected. It's kept when the cell is waitlisted or honorary.
+	if cell.payment != "" {
+		buffer.WriteString(fmt.Sprintf("\"%s\":\"%s\",", "payment", cell.payment))
+	}
+
+	// label field, used by reserved cells
+	if cell.label != "" {
+		labelJSON, err := json.Marshal(cell.label)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "label", string(labelJSON)))
+	}
+
+	// fill_percent field, only used by community and in progress cells
+	if cell.status == CellCommunity || cell.status == CellInProgress {
+		buffer.WriteString(fmt.Sprintf("\"%s\":%.1f,", "fill_percent", 100*cell.fill))
+	}
+
+	// projected_completion field, only used by in progress cells
+	if !cell.completion.IsZero() {
+		buffer.WriteString(fmt.Sprintf("\"%s\":\"%s\",", "projected_completion", cell.completion.Format("2006-01-02")))
+	}
+
+	// placement field, only used when a donor asked for a spot
+	if cell.placement != "" {
+		buffer.WriteString(fmt.Sprintf("\"%s\":\"%s\",", "placement", cell.placement))
+	}
+
+	// queue_position field, only used by the waitlist
+	if cell.queuePos > 0 {
+		buffer.WriteString(fmt.Sprintf("\"%s\":%d,", "queue_position", cell.queuePos))
+	}
+
+	// energy_kwh field, only used once telemetry has been attached
+	if cell.energy > 0 {
+		buffer.WriteString(fmt.Sprintf("\"%s\":%.3f,", "energy_kwh", cell.energy))
+	}
+
+	// originally and moves fields, only used for transferred adoptions
+	if len(cell.history) > 0 {
+		buffer.WriteString(fmt.Sprintf("\"%s\":%d,", "originally", cell.history[0].From))
+		buffer.WriteString("\"moves\":[")
+		for i, move := range cell.history {
+			reasonJSON, err := json.Marshal(move.Reason)
+			if err != nil {
+				return nil, err
+			}
+			buffer.WriteString(fmt.Sprintf("{\"from\":%d,\"to\":%d,\"reason\":%s,\"time\":\"%s\"}",
+				move.From, move.To, string(reasonJSON), move.Time.Format(time.RFC3339)))
+			if i < len(cell.history)-1 {
+				buffer.WriteRune(',')
+			}
+		}
+		buffer.WriteString("],")
+	}
+
+	// matched_by and matching_donor_ids fields, only used by cells funded
+	// by a matching gift
+	if len(cell.matchedBy) > 0 {
+		sponsorsJSON, err := json.Marshal(cell.matchedBy)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "matched_by", string(sponsorsJSON)))
+	}
+	if len(cell.matchingFor) > 0 {
+		donorsJSON, err := json.Marshal(cell.matchingFor)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "matching_donor_ids", string(donorsJSON)))
+	}
+
+	// position field
+	posJSON, err := json.Marshal(cell.pos)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "position", string(posJSON)))
+
 	// adoptee_ids field
 	adopteesJSON, err := json.Marshal(cell.adopteeIDs)
 	if err != nil {
 		return nil, err
 	}
-	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "adoptee_ids", string(adopteesJSON)))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "adoptee_ids", string(adopteesJSON)))
+
+	// named_adoptees field
+	buffer.WriteString("\"named_adoptees\":[")
+	for i, named := range cell.named {
+		namedJSON, err := json.Marshal(named)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(namedJSON)
+		if i < len(cell.named)-1 {
+			buffer.WriteRune(',')
+		}
+	}
+	buffer.WriteString("],")
+
+	// dedications field
+	buffer.WriteString("\"dedications\":[")
+	for i, msg := range cell.dedications {
+		msgJSON, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(msgJSON)
+		if i < len(cell.dedications)-1 {
+			buffer.WriteRune(',')
+		}
+	}
+	buffer.WriteRune(']')
 
 	buffer.WriteRune('}')
 	//fmt.Println(string(buffer.Bytes()))
 	return buffer.Bytes(), nil
 }
 
+// MarshalJSON formats a namedAdoptee for the published data. The gifted_by
+// field is left out unless the cell was a gift and the payer can be shown.
+func (named namedAdoptee) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+
+	idJSON, err := json.Marshal(named.patronID)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "patron_id", string(idJSON)))
+
+	nameJSON, err := json.Marshal(named.name)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "name", string(nameJSON)))
+
+	if named.giftedBy != "" {
+		giverJSON, err := json.Marshal(named.giftedBy)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf(",\"%s\":%s", "gifted_by", string(giverJSON)))
+	}
+
+	buffer.WriteRune('}')
+	return buffer.Bytes(), nil
+}
+
+// MarshalJSON formats a dedication for the published data. The text of a
+// message is only included once it has been approved.
+func (msg dedication) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+
+	idJSON, err := json.Marshal(msg.patronID)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "patron_id", string(idJSON)))
+
+	stateJSON, err := json.Marshal(msg.state)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "status", string(stateJSON)))
+
+	if msg.state == MessageApproved {
+		textJSON, err := json.Marshal(msg.text)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf(",\"%s\":%s", "text", string(textJSON)))
+	}
+
+	buffer.WriteRune('}')
+	return buffer.Bytes(), nil
+}
+
 func (list CellList) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString("{")
 
@@ -172,6 +497,51 @@ func (list CellList) MarshalJSON() ([]byte, error) {
 	}
 
 	buffer.WriteString("],")
+
+	// Marshall in the adoptions that didn't fit on the array
+	buffer.WriteString("\"overflow\":[")
+	for i, cell := range list.overflow {
+		cellJSON, err := json.Marshal(cell)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(string(cellJSON))
+
+		if i < len(list.overflow)-1 {
+			buffer.WriteRune(',')
+		}
+	}
+	buffer.WriteString("],")
+
+	// Marshall in the cells held back for reservations
+	buffer.WriteString("\"reserved\":[")
+	for i, cell := range list.reserved {
+		cellJSON, err := json.Marshal(cell)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(string(cellJSON))
+
+		if i < len(list.reserved)-1 {
+			buffer.WriteRune(',')
+		}
+	}
+	buffer.WriteString("],")
+
+	// Marshall in the capacity summary
+	capacityJSON, err := json.Marshal(map[string]interface{}{
+		"total":         list.capacity,
+		"adopted":       list.onArray(),
+		"pending":       list.countStatus(CellPending),
+		"reserved":      len(list.reserved),
+		"overflow":      len(list.overflow),
+		"overflow_mode": list.overflowMode,
+		"percent_full":  list.PercentFull(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "capacity", string(capacityJSON)))
 	// Marshall in the credit value
 	creditJSON, err := json.Marshal(list.credit)
 	if err != nil {
@@ -179,6 +549,13 @@ func (list CellList) MarshalJSON() ([]byte, error) {
 	}
 	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "credit", string(creditJSON)))
 
+	// Marshall in the credit policy
+	policyJSON, err := json.Marshal(list.creditPolicy)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "credit_policy", string(policyJSON)))
+
 	//Marshall in the remainingPatrons ids
 	buffer.WriteString("\"remaining\": [")
 	i := 0
@@ -202,6 +579,56 @@ func (list CellList) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "patron_list", string(patronsJSON)))
+
+	// Marshal in the array geometry so the graphic can draw empty cells too.
+	var positions []*Position
+	if list.layout != nil {
+		positions = list.layout.Positions()
+	}
+	layoutJSON, err := json.Marshal(positions)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "layout", string(layoutJSON)))
+
+	// Marshal in when the data was frozen, once the campaign has closed.
+	if !list.frozenAt.IsZero() {
+		buffer.WriteString(fmt.Sprintf("\"%s\":true,", "final"))
+		buffer.WriteString(fmt.Sprintf("\"%s\":\"%s\",", "frozen_at", list.frozenAt.Format(time.RFC3339)))
+	}
+
+	// Marshal in the progress toward the campaign goal.
+	if list.goal != nil {
+		goalJSON, err := list.goalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "goal", string(goalJSON)))
+	}
+
+	// Marshal in the leaderboards and recognition tiers.
+	if list.recognition != nil {
+		boardsJSON, err := list.leaderboardsJSON()
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "leaderboards", string(boardsJSON)))
+
+		tiersJSON, err := list.tiersJSON()
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "tiers", string(tiersJSON)))
+	}
+
+	// Marshal in the per-donor energy totals when telemetry is available.
+	if list.hasEnergy {
+		energyJSON, err := json.Marshal(list.DonorEnergy())
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "donor_energy", string(energyJSON)))
+	}
 	// Append update time to the data.
 	year, month, day := list.updateTime.Date()
 	hour, min, sec := list.updateTime.Clock()
@@ -237,6 +664,122 @@ func (list CellList) String() string {
 	return string(out)
 }
 
+// Leftover returns the patrons whose pledges are still sitting in the credit
+// pool, ordered by id.
+func (list *CellList) Leftover() []*Patron {
+	return sortedPatrons(list.remainingPatrons)
+}
+
+// PlacementReport counts the placement requests by result, per donor rather
+// than per cell.
+func (list *CellList) PlacementReport() map[string]int {
+	report := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, cell := range append(list.cells, list.overflow...) {
+		if cell.placement == "" || seen[cell.owner] {
+			continue
+		}
+		seen[cell.owner] = true
+		report[cell.placement]++
+	}
+	return report
+}
+
+// Totals is a summary of a CellList, used for the campaign statistics. Cells
+// only counts whole cells adopted by a donor, including the Pending ones that
+// aren't paid yet. Community cells, partly funded community cells and
+// recurring donors' cells still in progress are counted separately.
+type Totals struct {
+	Raised     int     `json:"raised"`
+	Donors     int     `json:"donors"`
+	Pledges    int     `json:"pledges"`
+	Cells      int     `json:"cells"`
+	Pending    int     `json:"pending"`
+	Community  int     `json:"community"`
+	Partial    int     `json:"partial"`
+	InProgress int     `json:"in_progress"`
+	Credit     float32 `json:"credit"`
+}
+
+// Totals sums up the CellList.
+func (list *CellList) Totals() Totals {
+	totals := Totals{
+		Raised:     list.patrons.totalRaised,
+		Donors:     len(list.patrons.patrons),
+		Cells:      list.countStatus(CellAdopted) + list.countStatus(CellPending),
+		Pending:    list.countStatus(CellPending),
+		InProgress: list.countStatus(CellInProgress),
+		Credit:     list.credit,
+	}
+	for _, cell := range list.cells {
+		if cell.partial() {
+			totals.Partial++
+		} else if cell.status == CellCommunity {
+			totals.Community++
+		}
+	}
+	totals.Pledges = len(list.Pledges())
+	return totals
+}
+
+// Patrons returns the Patrons behind the CellList, including any matching
+// sponsors.
+func (list *CellList) Patrons() []*Patron {
+	return list.patrons.Patrons()
+}
+
+// Pledges returns every individual pledge behind the CellList, oldest first.
+// Credit carried in from an earlier campaign, refunded or cancelled pledges and
+// the funds of matching sponsors are left out.
+func (list *CellList) Pledges() []Pledge {
+	var pledges []Pledge
+	for _, patron := range list.patrons.patrons {
+		if !patron.carried && !patron.withdrawn() && len(patron.sponsorFor) == 0 {
+			pledges = append(pledges, patron.pledges...)
+		}
+	}
+	sort.SliceStable(pledges, func(i, j int) bool {
+		return pledges[i].Time.Before(pledges[j].Time)
+	})
+	return pledges
+}
+
+// SetFinal marks the CellList as the final result of a closed campaign.
+func (list *CellList) SetFinal(frozenAt time.Time) {
+	list.frozenAt = frozenAt
+}
+
+// countStatus returns how many placed cells have the given status.
+func (list *CellList) countStatus(status string) int {
+	count := 0
+	for _, cell := range list.cells {
+		if cell.status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// onArray returns how many cells take up a place on the array.
+func (list *CellList) onArray() int {
+	count := 0
+	for _, cell := range list.cells {
+		if !cell.partial() {
+			count++
+		}
+	}
+	return count
+}
+
+// PercentFull returns how much of the array's capacity has been adopted or
+// reserved. An unlimited array is always reported as 0% full.
+func (list *CellList) PercentFull() float64 {
+	if list.capacity == 0 {
+		return 0
+	}
+	return 100 * float64(list.onArray()+len(list.reserved)) / float64(list.capacity)
+}
+
 // ToJSONFile writes the contents of the CellList to a JSON-formatted text file.
 func (list *CellList) ToJSONFile(fileName string) error {
 	// First, confirm that the directory exists.
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/logging"
+)
+
+// CreditPolicy decides what happens to small donors whose pledges don't add
+// up to a whole cell.
+type CreditPolicy string
+
+const (
+	// CreditHold keeps leftover credit in the credit pool until it can be
+	// paired up. This is the original behavior.
+	CreditHold CreditPolicy = "hold"
+	// CreditCommunity rolls leftover credit into shared community cells, each
+	// listing all of its contributors. Credit that can't fill a whole
+	// community cell stays in the pool.
+	CreditCommunity CreditPolicy = "community"
+	// CreditPartial works like CreditCommunity, but the last community cell is
+	// shown partially filled instead of being held back.
+	CreditPartial CreditPolicy = "partial"
+	// CreditCarry leaves the leftover credit off of the array so it can be
+	// carried forward into the next campaign.
+	CreditCarry CreditPolicy = "carry"
+
+	// CellCommunity is the status of a cell shared by leftover contributors.
+	CellCommunity string = "community"
+)
+
+// ParseCreditPolicy converts a policy name into a CreditPolicy.
+func ParseCreditPolicy(name string) (CreditPolicy, error) {
+	switch policy := CreditPolicy(strings.ToLower(name)); policy {
+	case CreditHold, CreditCommunity, CreditPartial, CreditCarry:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown credit policy %q", name)
+}
+
+// sortedPatrons returns the patrons in the map ordered by id, so that the
+// leftover pool is handled the same way on every run.
+func sortedPatrons(patronMap map[int]*Patron) []*Patron {
+	patrons := make([]*Patron, 0, len(patronMap))
+	for _, patron := range patronMap {
+		patrons = append(patrons, patron)
+	}
+	sort.Slice(patrons, func(i, j int) bool {
+		return patrons[i].id < patrons[j].id
+	})
+	return patrons
+}
+
+// communityCells rolls the leftover patrons into community cells according to
+// the policy. Contributors are added to a cell in pledge order until it's
+// full. The new cells are returned along with whatever is still left over.
+func communityCells(leftover map[int]*Patron, policy CreditPolicy, nextID int, logger *logging.Logger) ([]*Cell, map[int]*Patron, float32) {
+	var credit float32
+	for _, patron := range leftover {
+		credit += patron.cellAmt
+	}
+	if policy != CreditCommunity && policy != CreditPartial {
+		return nil, leftover, credit
+	}
+
+	var cells []*Cell
+	var group []*Patron
+	var filled float32
+	remaining := make(map[int]*Patron)
+
+	for _, patron := range sortedPatrons(leftover) {
+		group = append(group, patron)
+		filled += patron.cellAmt
+		if filled >= 1 {
+			cells = append(cells, newCommunityCell(nextID, group, 1, logger))
+			nextID++
+			group, filled = nil, 0
+		}
+	}
+
+	if len(group) > 0 && policy == CreditPartial {
+		cells = append(cells, newCommunityCell(nextID, group, filled, logger))
+		group, filled = nil, 0
+	}
+	for _, patron := range group {
+		remaining[patron.id] = patron
+	}
+
+	return cells, remaining, filled
+}
+
+// newCommunityCell creates a cell shared by every contributor in group. The
+// fill is how much of the cell their pledges cover, from 0 to 1.
+func newCommunityCell(id int, group []*Patron, fill float32, logger *logging.Logger) *Cell {
+	cell := newCell(id, group, logger)
+	cell.status = CellCommunity
+	cell.fill = fill
+	markPending(cell)
+	return cell
+}
+
+// partial reports whether the cell is a community cell that isn't full yet.
+// Nobody has paid for the rest of it, so it isn't given a place on the array
+// and doesn't count toward the capacity.
+func (cell *Cell) partial() bool {
+	return cell.status == CellCommunity && cell.fill < 1
+}
+
+// Carryover is a leftover pledge being carried forward into the next campaign.
+type Carryover struct {
+	Name       string `json:"name"`
+	Anonymous  bool   `json:"anonymous"`
+	PledgeTime string `json:"pledge_time"`
+	Amount     int    `json:"amount"`
+}
+
+// Carryover returns the pledges left in the credit pool in a form that can be
+// saved and loaded into the next campaign.
+func (list *CellList) Carryover() []Carryover {
+	var carried []Carryover
+	for _, patron := range list.Leftover() {
+		carried = append(carried, Carryover{
+			Name:       patron.sourceName,
+			Anonymous:  patron.anonymous,
+			PledgeTime: patron.pledgeTime.Format("2006-01-02 15:04:05"),
+			Amount:     patron.pledgeAmt,
+		})
+	}
+	return carried
+}
+
+// CarriedPatrons turns pledges carried over from an earlier campaign back into
+// patrons. Their ids start at firstID. The earlier campaign already counted
+// the money, so it isn't added to what this campaign has raised.
+func CarriedPatrons(carried []Carryover, firstID int) []*Patron {
+	var patrons []*Patron
+	for i, entry := range carried {
+		names := strings.SplitN(entry.Name, " ", 2)
+		names = append(names, "")
+		patron := NewPatron(firstID+i, entry.PledgeTime, entry.Anonymous, names[0], names[1], entry.Amount)
+		patron.carried = true
+		patrons = append(patrons, patron)
+	}
+	return patrons
+}
+
+// Carried reports whether the Patron is credit carried in from an earlier
+// campaign.
+func (patron *Patron) Carried() bool {
+	return patron.carried
+}
@@ -0,0 +1,139 @@
+package data_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// creditPatrons returns a donor with two whole cells and five small donors
+// whose pledges add up to two more.
+func creditPatrons() []*data.Patron {
+	patrons := []*data.Patron{data.NewPatron(1, "2019-03-30 08:00:00", false, "Jane", "Smith", 100)}
+	for i, name := range []string{"Ann", "Bob", "Cat", "Dan", "Eve"} {
+		patrons = append(patrons, data.NewPatron(i+2, fmt.Sprintf("2019-03-31 %02d:00:00", i+1), false, name, "Lee", 20))
+	}
+	return patrons
+}
+
+type creditJSON struct {
+	Cells []struct {
+		Status string  `json:"status"`
+		Fill   float64 `json:"fill_percent"`
+	} `json:"cells"`
+	Capacity struct {
+		Adopted int `json:"adopted"`
+	} `json:"capacity"`
+	Credit     float64 `json:"credit"`
+	PatronList struct {
+		TotalRaised int `json:"total_raised"`
+		CarriedIn   int `json:"carried_in"`
+	} `json:"patron_list"`
+}
+
+func publishCredit(t *testing.T, list *data.CellList) creditJSON {
+	var published creditJSON
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	return published
+}
+
+func TestCreditPolicies(t *testing.T) {
+	tests := []struct {
+		policy    data.CreditPolicy
+		community int
+		partial   int
+		onArray   int
+		credit    float64
+	}{
+		{data.CreditHold, 0, 0, 2, 2},
+		{data.CreditCommunity, 1, 0, 3, 0.8},
+		{data.CreditPartial, 1, 1, 3, 0},
+		{data.CreditCarry, 0, 0, 2, 2},
+	}
+
+	for _, test := range tests {
+		list := data.NewCellList(data.NewPatronList(creditPatrons()), &data.CellOptions{Credit: test.policy})
+		published := publishCredit(t, list)
+
+		var community, partial int
+		for _, cell := range published.Cells {
+			if cell.Status != data.CellCommunity {
+				continue
+			}
+			if cell.Fill < 100 {
+				partial++
+			} else {
+				community++
+			}
+		}
+		if community != test.community || partial != test.partial {
+			t.Error("For", test.policy, "expected", test.community, test.partial, "got", community, partial)
+		}
+		totals := list.Totals()
+		if totals.Cells != 2 || totals.Community != test.community || totals.Partial != test.partial {
+			t.Error("For", test.policy, "expected", 2, test.community, test.partial, "got", totals.Cells, totals.Community, totals.Partial)
+		}
+		if published.Capacity.Adopted != test.onArray {
+			t.Error("For", test.policy, "expected", test.onArray, "cells on the array", "got", published.Capacity.Adopted)
+		}
+		if published.Credit < test.credit-0.01 || published.Credit > test.credit+0.01 {
+			t.Error("For", test.policy, "expected", test.credit, "credit", "got", published.Credit)
+		}
+	}
+}
+
+func TestCommunityCellsFollowPayment(t *testing.T) {
+	patrons := creditPatrons()
+	for _, patron := range patrons[1:] {
+		patron.SetPayment(data.PaymentPledged)
+	}
+	list := data.NewCellList(data.NewPatronList(patrons), &data.CellOptions{Credit: data.CreditCommunity})
+
+	var published struct {
+		Cells []struct {
+			Status  string `json:"status"`
+			Payment string `json:"payment"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	community := 0
+	for _, cell := range published.Cells {
+		if cell.Status != data.CellCommunity {
+			continue
+		}
+		community++
+		if cell.Payment != data.PaymentPledged {
+			t.Error("For", "community cell with unpaid contributors", "expected", data.PaymentPledged, "got", cell.Payment)
+		}
+	}
+	if community != 1 {
+		t.Error("For", "community cells", "expected", 1, "got", community)
+	}
+}
+
+func TestCarryover(t *testing.T) {
+	list := data.NewCellList(data.NewPatronList(creditPatrons()), &data.CellOptions{Credit: data.CreditCarry})
+	carried := list.Carryover()
+	if len(carried) != 5 {
+		t.Fatal("For", "Carryover()", "expected", 5, "got", len(carried))
+	}
+
+	// The next campaign gets the credit, but not as money it raised.
+	next := []*data.Patron{data.NewPatron(1, "2020-03-30 08:00:00", false, "Kim", "Park", 50)}
+	next = append(next, data.CarriedPatrons(carried, 2)...)
+	published := publishCredit(t, data.NewCellList(data.NewPatronList(next), &data.CellOptions{Credit: data.CreditCommunity}))
+
+	if published.PatronList.TotalRaised != 50 || published.PatronList.CarriedIn != 100 {
+		t.Error("For", "carried credit", "expected", "50 raised / 100 carried in", "got",
+			published.PatronList.TotalRaised, published.PatronList.CarriedIn)
+	}
+	if published.Capacity.Adopted != 2 {
+		t.Error("For", "carried credit", "expected", "2 cells", "got", published.Capacity.Adopted)
+	}
+}
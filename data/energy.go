@@ -0,0 +1,52 @@
+package data
+
+import "sort"
+
+// DonorEnergy is the estimated energy made by all of a donor's cells.
+type DonorEnergy struct {
+	PatronID int     `json:"patron_id"`
+	KWh      float64 `json:"kwh"`
+}
+
+// SetEnergy attaches the energy estimate in kWh for each position to the cells
+// placed there. Cells without a position on the array are left at zero.
+func (list *CellList) SetEnergy(perCell map[int]float64) {
+	list.hasEnergy = true
+	for _, cell := range list.cells {
+		if cell.pos != nil {
+			cell.energy = perCell[cell.pos.ID]
+		}
+	}
+}
+
+// DonorEnergy totals the energy of every adopted cell by donor. A shared cell's
+// energy is split between its adoptees in proportion to what each paid toward
+// it.
+func (list *CellList) DonorEnergy() []DonorEnergy {
+	totals := make(map[int]float64)
+	for _, cell := range list.cells {
+		if cell.energy == 0 {
+			continue
+		}
+		var paid float32
+		for _, adoptee := range cell.adoptees {
+			paid += adoptee.cellAmt
+		}
+		for _, adoptee := range cell.adoptees {
+			share := 1 / float64(len(cell.adoptees))
+			if paid > 0 {
+				share = float64(adoptee.cellAmt / paid)
+			}
+			totals[adoptee.id] += cell.energy * share
+		}
+	}
+
+	donors := make([]DonorEnergy, 0, len(totals))
+	for id, kwh := range totals {
+		donors = append(donors, DonorEnergy{PatronID: id, KWh: kwh})
+	}
+	sort.Slice(donors, func(i, j int) bool {
+		return donors[i].PatronID < donors[j].PatronID
+	})
+	return donors
+}
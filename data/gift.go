@@ -0,0 +1,96 @@
+package data
+
+import (
+	"strings"
+)
+
+// Gift records that a pledge was made on behalf of someone else. The honoree is
+// the name shown on the cell, while the Patron who paid is only shown as
+// "gifted by" if they allow it. Each side has its own privacy setting.
+type Gift struct {
+	honoree   string
+	anonymous bool
+	hideGiver bool
+	pending   bool
+}
+
+// honoreeHeaders lists the column headings that may hold a gift recipient.
+var honoreeHeaders = []string{"honoree", "gift recipient", "recipient"}
+
+// honoreeAnonHeaders lists the column headings that may hold the honoree's
+// anonymity setting.
+var honoreeAnonHeaders = []string{"honoree anonymous", "recipient anonymous"}
+
+// hideGiverHeaders lists the column headings that may hold the donor's choice
+// to leave "gifted by" off of the cell.
+var hideGiverHeaders = []string{"hide gifted by", "hide giver"}
+
+// NewGift returns a pointer to a Gift for the named honoree. Setting anon hides
+// the honoree's name, and setting hideGiver leaves the payer off of the cell.
+func NewGift(honoree string, anon, hideGiver bool) *Gift {
+	return &Gift{
+		honoree:   strings.TrimSpace(honoree),
+		anonymous: anon,
+		hideGiver: hideGiver,
+	}
+}
+
+// Honoree returns the honoree's name as it was given. It must only be
+// published through DisplayName().
+func (gift *Gift) Honoree() string {
+	return gift.honoree
+}
+
+// Anonymous reports whether the honoree's name should be hidden.
+func (gift *Gift) Anonymous() bool {
+	return gift.anonymous
+}
+
+// HideGiver reports whether the Patron who paid is left off of the cell.
+func (gift *Gift) HideGiver() bool {
+	return gift.hideGiver
+}
+
+// DisplayName returns the honoree's name as it should appear on the cell.
+func (gift *Gift) DisplayName() string {
+	switch {
+	case gift.anonymous:
+		return "Anonymous"
+	case gift.pending:
+		return "Pending Review"
+	default:
+		return gift.honoree
+	}
+}
+
+// HoldForReview hides the honoree's name until an operator approves it.
+func (gift *Gift) HoldForReview() {
+	gift.pending = true
+}
+
+// Redact permanently hides the honoree's name. This is used for names that an
+// operator has rejected.
+func (gift *Gift) Redact() {
+	gift.pending = false
+	gift.anonymous = true
+}
+
+// Gift returns the Gift attached to the Patron's pledge, or nil if the Patron
+// adopted in their own name.
+func (patron *Patron) Gift() *Gift {
+	return patron.gift
+}
+
+// SetGift attaches a Gift to the Patron's pledge. Passing nil removes it.
+func (patron *Patron) SetGift(gift *Gift) {
+	patron.gift = gift
+}
+
+// parseYes reports whether a yes/no export value means yes.
+func parseYes(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "true", "1":
+		return true
+	}
+	return false
+}
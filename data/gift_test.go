@@ -0,0 +1,115 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// publishedNames returns the names shown on the first cell of the list.
+func publishedNames(t *testing.T, list *data.CellList) (string, string) {
+	var published struct {
+		Cells []struct {
+			Named []struct {
+				Name     string `json:"name"`
+				GiftedBy string `json:"gifted_by"`
+			} `json:"named_adoptees"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	if len(published.Cells) == 0 || len(published.Cells[0].Named) == 0 {
+		t.Fatal("For", "published cells", "expected", "a named adoptee", "got", list.String())
+	}
+	named := published.Cells[0].Named[0]
+	return named.Name, named.GiftedBy
+}
+
+func TestGiftNames(t *testing.T) {
+	tests := []struct {
+		anonGiver   bool
+		anonHonoree bool
+		hideGiver   bool
+		review      bool
+		name        string
+		giftedBy    string
+	}{
+		{false, false, false, false, "Sam Smith", "Jane Smith"},
+		{false, false, true, false, "Sam Smith", ""},
+		{true, false, false, false, "Sam Smith", ""},
+		{false, true, false, false, "Anonymous", "Jane Smith"},
+		{true, true, true, false, "Anonymous", ""},
+		{false, false, false, true, "Pending Review", "Jane Smith"},
+	}
+	for _, test := range tests {
+		jane := data.NewPatron(1, "2019-03-31 08:21:16", test.anonGiver, "Jane", "Smith", 50)
+		gift := data.NewGift("Sam Smith", test.anonHonoree, test.hideGiver)
+		if test.review {
+			gift.HoldForReview()
+		}
+		jane.SetGift(gift)
+
+		list := data.NewCellList(data.NewPatronList([]*data.Patron{jane}), nil)
+		if name, giftedBy := publishedNames(t, list); name != test.name || giftedBy != test.giftedBy {
+			t.Error("For", test, "expected", test.name, test.giftedBy, "got", name, giftedBy)
+		}
+	}
+}
+
+func TestGiftFromExportAndOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	export := "[" + quotedLine("Time", "Anonymous", "Name", "Amount", "Honoree", "Honoree Anonymous", "Hide Giver") + "," +
+		quotedLine("2019-03-31 08:21:16", "no", "Jane Smith", "50", "Sam Smith", "no", "yes") + "," +
+		quotedLine("2019-04-01 10:00:00", "no", "John Doe", "50", "", "", "") + "]"
+	patrons := data.GetPatronData(export)
+	if len(patrons) != 2 {
+		t.Fatal("For", "patrons", "expected", 2, "got", len(patrons))
+	}
+	if gift := patrons[0].Gift(); gift == nil || gift.Honoree() != "Sam Smith" || gift.Anonymous() || !gift.HideGiver() {
+		t.Error("For", "exported gift", "expected", "Sam Smith, giver hidden", "got", gift)
+	}
+	if gift := patrons[1].Gift(); gift != nil {
+		t.Error("For", "John Doe", "expected", "no gift", "got", gift.Honoree())
+	}
+
+	// The overlay replaces the exported gift and adds one to John's pledge.
+	overlayFile := path.Join(dir, "overlay.json")
+	ioutil.WriteFile(overlayFile, []byte(`[
+		{"name": "Jane Smith", "honoree": "Grandma Smith", "honoree_anonymous": true},
+		{"name": "John Doe", "honoree": "Ann Doe"}
+	]`), 0644)
+	overlay, err := data.LoadOverlay(overlayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay.Apply(patrons)
+
+	tests := []struct {
+		honoree   string
+		anonymous bool
+		hideGiver bool
+	}{
+		{"Grandma Smith", true, false},
+		{"Ann Doe", false, false},
+	}
+	for i, test := range tests {
+		gift := patrons[i].Gift()
+		if gift == nil {
+			t.Error("For", patrons[i].SourceName(), "expected", test.honoree, "got", "no gift")
+			continue
+		}
+		if gift.Honoree() != test.honoree || gift.Anonymous() != test.anonymous || gift.HideGiver() != test.hideGiver {
+			t.Error("For", patrons[i].SourceName(), "expected", test, "got", gift.Honoree(), gift.Anonymous(), gift.HideGiver())
+		}
+	}
+}
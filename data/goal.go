@@ -0,0 +1,158 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GoalUnit is what a campaign goal is counted in.
+type GoalUnit string
+
+const (
+	// GoalDollars counts the dollars raised, including matched funds.
+	GoalDollars GoalUnit = "dollars"
+	// GoalCells counts the cells paid for, including partial cells.
+	GoalCells GoalUnit = "cells"
+
+	// goalName is the name of the milestone for reaching the target itself.
+	goalName string = "Goal"
+)
+
+// Milestone is a named point along the way to, or past, the campaign goal.
+// Stretch milestones lie beyond the target.
+type Milestone struct {
+	Name    string  `json:"name"`
+	Amount  float64 `json:"amount"`
+	Stretch bool    `json:"stretch,omitempty"`
+}
+
+// Goal is the campaign's target, in dollars or cells, with its milestones.
+type Goal struct {
+	Unit       GoalUnit    `json:"unit"`
+	Target     float64     `json:"target"`
+	Milestones []Milestone `json:"milestones"`
+}
+
+// LoadGoal reads the goal file at fileName. Milestones above the target are
+// treated as stretch goals whether or not they're marked as one.
+func LoadGoal(fileName string) (*Goal, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	goal := &Goal{}
+	if err := json.Unmarshal(content, goal); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	switch goal.Unit = GoalUnit(strings.ToLower(string(goal.Unit))); goal.Unit {
+	case "":
+		goal.Unit = GoalDollars
+	case GoalDollars, GoalCells:
+	default:
+		return nil, fmt.Errorf("%s: unknown goal unit %q", fileName, goal.Unit)
+	}
+	if goal.Target <= 0 {
+		return nil, fmt.Errorf("%s: the goal needs a target above 0", fileName)
+	}
+
+	names := map[string]bool{strings.ToLower(goalName): true}
+	for i := range goal.Milestones {
+		milestone := &goal.Milestones[i]
+		if milestone.Name == "" || milestone.Amount <= 0 {
+			return nil, fmt.Errorf("%s: milestone %d needs a name and an amount above 0", fileName, i)
+		}
+		if names[strings.ToLower(milestone.Name)] {
+			return nil, fmt.Errorf("%s: milestone %q is defined twice", fileName, milestone.Name)
+		}
+		names[strings.ToLower(milestone.Name)] = true
+		if milestone.Amount > goal.Target {
+			milestone.Stretch = true
+		}
+	}
+
+	return goal, nil
+}
+
+// All returns every milestone in order of amount, including the target
+// itself.
+func (goal *Goal) All() []Milestone {
+	all := append([]Milestone{{Name: goalName, Amount: goal.Target}}, goal.Milestones...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Amount < all[j].Amount
+	})
+	return all
+}
+
+// Raised returns the progress toward a goal counted in unit.
+func (list *CellList) Raised(unit GoalUnit) float64 {
+	if unit == GoalCells {
+		return float64(list.patrons.totalCells)
+	}
+	return float64(list.patrons.totalRaised)
+}
+
+// SetGoal attaches the campaign goal to the CellList so that its progress is
+// published. reached holds when each milestone was first reached. That time is
+// always published as first_reached_at, but reached_at is left out while
+// refunds keep the milestone unreached.
+func (list *CellList) SetGoal(goal *Goal, reached map[string]time.Time) {
+	list.goal = goal
+	list.reached = reached
+}
+
+// goalJSON formats the progress toward the goal for the published data.
+func (list *CellList) goalJSON() ([]byte, error) {
+	goal := list.goal
+	raised := list.Raised(goal.Unit)
+
+	type milestoneJSON struct {
+		Milestone
+		Reached        bool   `json:"reached"`
+		ReachedAt      string `json:"reached_at,omitempty"`
+		FirstReachedAt string `json:"first_reached_at,omitempty"`
+	}
+	var milestones []milestoneJSON
+	var next *Milestone
+	for _, milestone := range goal.All() {
+		entry := milestoneJSON{Milestone: milestone, Reached: raised >= milestone.Amount}
+		if at, ok := list.reached[milestone.Name]; ok {
+			entry.FirstReachedAt = at.Format(time.RFC3339)
+			if entry.Reached {
+				entry.ReachedAt = entry.FirstReachedAt
+			}
+		}
+		if !entry.Reached && next == nil {
+			m := milestone
+			next = &m
+		}
+		milestones = append(milestones, entry)
+	}
+
+	buffer := bytes.NewBufferString("{")
+	buffer.WriteString(fmt.Sprintf("\"%s\":\"%s\",", "unit", goal.Unit))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%g,", "target", goal.Target))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%g,", "raised", raised))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%.1f,", "percent", 100*raised/goal.Target))
+
+	milestonesJSON, err := json.Marshal(milestones)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "milestones", string(milestonesJSON)))
+
+	nextJSON, err := json.Marshal(next)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "next_milestone", string(nextJSON)))
+
+	buffer.WriteRune('}')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestGoalProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	goalFile := path.Join(dir, "goal.json")
+	ioutil.WriteFile(goalFile, []byte(`{
+		"unit": "cells",
+		"target": 4,
+		"milestones": [{"name": "Halfway", "amount": 2}, {"name": "Spare pack", "amount": 6}]
+	}`), 0644)
+	goal, err := data.LoadGoal(goalFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 75),
+	}
+	list := data.NewCellList(data.NewPatronList(patrons), nil)
+
+	// Refunds have taken the campaign back below the spare pack.
+	firstReached := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	list.SetGoal(goal, map[string]time.Time{"Halfway": firstReached, "Spare pack": firstReached})
+
+	var published struct {
+		Goal struct {
+			Raised     float64 `json:"raised"`
+			Percent    float64 `json:"percent"`
+			Milestones []struct {
+				Name         string `json:"name"`
+				Stretch      bool   `json:"stretch"`
+				Reached      bool   `json:"reached"`
+				ReachedAt    string `json:"reached_at"`
+				FirstReached string `json:"first_reached_at"`
+			} `json:"milestones"`
+			Next struct {
+				Name string `json:"name"`
+			} `json:"next_milestone"`
+		} `json:"goal"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := published.Goal
+	if progress.Raised != 3.5 || progress.Percent != 87.5 || progress.Next.Name != "Goal" {
+		t.Error("For", "goal", "expected", "3.5 cells / 87.5% / next Goal", "got", progress.Raised, progress.Percent, progress.Next.Name)
+	}
+	if len(progress.Milestones) != 3 || !progress.Milestones[0].Reached || !progress.Milestones[2].Stretch {
+		t.Error("For", "milestones", "expected", "Halfway reached and a stretch goal", "got", progress.Milestones)
+	}
+
+	at := firstReached.Format(time.RFC3339)
+	if len(progress.Milestones) == 3 {
+		halfway, spare := progress.Milestones[0], progress.Milestones[2]
+		if halfway.ReachedAt != at || halfway.FirstReached != at {
+			t.Error("For", "Halfway", "expected", at, at, "got", halfway.ReachedAt, halfway.FirstReached)
+		}
+		if spare.Reached || spare.ReachedAt != "" || spare.FirstReached != at {
+			t.Error("For", "Spare pack", "expected", "unreached", "", at, "got", spare.Reached, spare.ReachedAt, spare.FirstReached)
+		}
+	}
+}
+
+func TestWithdrawnPledgesArentRaised(t *testing.T) {
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 75),
+		data.NewPatron(3, "2019-04-02 10:00:00", false, "Ann", "Lee", 50),
+	}
+	patrons[0].SetStatus(data.PledgeRefunded)
+	patrons[2].SetStatus(data.PledgeCancelled)
+	list := data.NewCellList(data.NewPatronList(patrons), nil)
+
+	if raised := list.Raised(data.GoalDollars); raised != 75 {
+		t.Error("For", "Raised()", "expected", 75, "got", raised)
+	}
+	if pledges := list.Pledges(); len(pledges) != 1 || pledges[0].Amount != 75 {
+		t.Error("For", "Pledges()", "expected", "John's $75", "got", pledges)
+	}
+	if totals := list.Totals(); totals.Raised != 75 || totals.Pledges != 1 {
+		t.Error("For", "Totals()", "expected", "$75 / 1 pledge", "got", totals.Raised, totals.Pledges)
+	}
+
+	var published struct {
+		PatronList struct {
+			TotalRaised int `json:"total_raised"`
+			Withdrawn   int `json:"withdrawn"`
+		} `json:"patron_list"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	if published.PatronList.TotalRaised != 75 || published.PatronList.Withdrawn != 150 {
+		t.Error("For", "patron_list", "expected", "75 raised / 150 withdrawn", "got", published.PatronList)
+	}
+}
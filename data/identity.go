@@ -0,0 +1,203 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MatchRule is a set of attributes that must all be present and equal for two
+// pledges to be treated as coming from the same donor. The supported attributes
+// are "name", "email", "phone" and "zip".
+type MatchRule []string
+
+// DefaultMatchRules only merges pledges that share an email address.
+const DefaultMatchRules string = "email"
+
+var (
+	matchAttrs   = map[string]bool{"name": true, "email": true, "phone": true, "zip": true}
+	nonDigits    = regexp.MustCompile(`[^0-9]`)
+	extraSpacing = regexp.MustCompile(`\s+`)
+)
+
+// ParseMatchRules reads a rule spec such as "email;name+zip". Rules are
+// separated by semicolons and are tried in order, and the attributes within a
+// rule are joined with '+'. An empty spec disables merging.
+func ParseMatchRules(spec string) ([]MatchRule, error) {
+	var rules []MatchRule
+	for _, ruleStr := range strings.Split(spec, ";") {
+		ruleStr = strings.TrimSpace(ruleStr)
+		if ruleStr == "" {
+			continue
+		}
+
+		var rule MatchRule
+		for _, attr := range strings.Split(ruleStr, "+") {
+			attr = strings.ToLower(strings.TrimSpace(attr))
+			if !matchAttrs[attr] {
+				return nil, fmt.Errorf("unknown match attribute %q in rule %q", attr, ruleStr)
+			}
+			rule = append(rule, attr)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// identity returns the normalized value of attr for the Patron, or "" if the
+// export didn't provide it.
+func (patron *Patron) identity(attr string) string {
+	switch attr {
+	case "name":
+		return strings.ToLower(extraSpacing.ReplaceAllString(patron.sourceName, " "))
+	case "phone":
+		return nonDigits.ReplaceAllString(patron.contact[attr], "")
+	default:
+		return strings.ToLower(strings.TrimSpace(patron.contact[attr]))
+	}
+}
+
+// key builds the lookup key for the rule, or "" if any attribute is missing.
+// Gift adoptions are keyed by their honoree as well so that a gift is never
+// merged into the donor's own adoption.
+func (rule MatchRule) key(patron *Patron) string {
+	parts := make([]string, 0, len(rule)+1)
+	for _, attr := range rule {
+		value := patron.identity(attr)
+		if value == "" {
+			return ""
+		}
+		parts = append(parts, value)
+	}
+	if patron.gift != nil {
+		parts = append(parts, "gift:"+strings.ToLower(patron.gift.honoree))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// MergeRepeatDonors combines every Patron that matches another under any of the
+// rules into a single Patron. Pledge amounts are summed before cells are
+// allocated, and the individual pledges are kept in the merged Patron's
+// history. The merged Patron takes the place of the donor's first pledge and
+// IDs are renumbered to stay sequential. Pledges that conflict, such as two
+// different dedications, are left apart.
+func MergeRepeatDonors(patrons []*Patron, rules []MatchRule) []*Patron {
+	if len(rules) == 0 {
+		return patrons
+	}
+
+	// Union-find over the patron indexes so that chains of matches, such as
+	// A~B by email and B~C by name, end up as one donor.
+	parent := make([]int, len(patrons))
+	members := make([][]*Patron, len(patrons))
+	for i := range parent {
+		parent[i] = i
+		members[i] = []*Patron{patrons[i]}
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for _, rule := range rules {
+		seen := make(map[string]int)
+		for i, patron := range patrons {
+			key := rule.key(patron)
+			if key == "" {
+				continue
+			}
+			if j, ok := seen[key]; ok {
+				a, b := find(i), find(j)
+				if a == b || conflicting(members[a], members[b]) {
+					continue
+				}
+				// The donor's first pledge is the root, so it keeps its
+				// place and its details win over later pledges.
+				if patrons[b].pledgeTime.Before(patrons[a].pledgeTime) {
+					a, b = b, a
+				}
+				parent[b] = a
+				members[a] = append(members[a], members[b]...)
+				members[b] = nil
+				continue
+			}
+			seen[key] = i
+		}
+	}
+
+	var merged []*Patron
+	for i, patron := range patrons {
+		root := find(i)
+		if root == i {
+			merged = append(merged, patron)
+			continue
+		}
+		patrons[root].absorb(patron)
+	}
+
+	for i, patron := range merged {
+		patron.id = i + 1
+	}
+	return merged
+}
+
+// conflicting reports whether any Patron in a conflicts with any in b. Pledges
+// conflict when they have different dedications, placement requests or
+// statuses, since the merged donor can only hold one of each.
+func conflicting(a, b []*Patron) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if differ(x.message, y.message) || differ(x.placement, y.placement) || x.status != y.status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// differ reports whether both values are set and aren't the same.
+func differ(a, b string) bool {
+	return a != "" && b != "" && a != b
+}
+
+// absorb folds other's pledges into the Patron. The earliest pledge time is
+// kept, and the donor is treated as anonymous if any of their pledges were.
+// Each pledge keeps its own payment status, and the gift, dedication and
+// placement are taken from other if the Patron doesn't have its own.
+func (patron *Patron) absorb(other *Patron) {
+	patron.pledges = append(patron.pledges, other.pledges...)
+	patron.pledgeAmt += other.pledgeAmt
+	patron.cellAmt = float32(patron.pledgeAmt) / float32(patron.cellPrice)
+
+	if other.pledgeTime.Before(patron.pledgeTime) {
+		patron.pledgeTime = other.pledgeTime
+	}
+	if other.anonymous && !patron.anonymous {
+		patron.anonymous = true
+		patron.firstName = "Anonymous"
+		patron.lastName = "Donor"
+	}
+	if patron.message == "" {
+		patron.message, patron.msgState = other.message, other.msgState
+	}
+	if patron.placement == "" {
+		patron.placement = other.placement
+	}
+	if patron.gift == nil {
+		patron.gift = other.gift
+	} else if other.gift != nil {
+		patron.gift.anonymous = patron.gift.anonymous || other.gift.anonymous
+		patron.gift.hideGiver = patron.gift.hideGiver || other.gift.hideGiver
+	}
+	if patron.subscription == "" {
+		patron.subscription = other.subscription
+	}
+	for attr, value := range other.contact {
+		if patron.contact[attr] == "" {
+			patron.contact[attr] = value
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestMergeRepeatDonors(t *testing.T) {
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 25),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 50),
+		data.NewPatron(3, "2019-04-02 12:30:00", false, "jane", "smith", 25),
+	}
+	gift := data.NewPatron(4, "2019-04-03 09:15:00", false, "Jane", "Smith", 50)
+	gift.SetGift(data.NewGift("Sam Smith", false, false))
+	patrons = append(patrons, gift)
+
+	rules, err := data.ParseMatchRules("email;name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := data.MergeRepeatDonors(patrons, rules)
+
+	if len(merged) != 3 {
+		t.Fatal(
+			"For", "TestMergeRepeatDonors()",
+			"expected", 3,
+			"got", len(merged),
+		)
+	}
+	jane := merged[0]
+	if jane.PledgeAmt() != 50 || jane.CellAmt() != 1 || len(jane.Pledges()) != 2 {
+		t.Error(
+			"For", "merged donor",
+			"expected", "50 / 1 cell / 2 pledges",
+			"got", jane.PledgeAmt(), jane.CellAmt(), len(jane.Pledges()),
+		)
+	}
+	if merged[2].Gift() == nil {
+		t.Error("Gift adoption was merged into the donor's own pledges")
+	}
+}
+
+func TestMergeKeepsFirstPledge(t *testing.T) {
+	// The export lists the newest pledge first.
+	newest := data.NewPatron(1, "2019-04-05 10:00:00", false, "Jane", "Smith", 50)
+	newest.SetPayment(data.PaymentPledged)
+	other := data.NewPatron(2, "2019-04-03 10:00:00", false, "John", "Doe", 50)
+	oldest := data.NewPatron(3, "2019-04-01 10:00:00", false, "Jane", "Smith", 50)
+	oldest.SetMessage("For Mom")
+	oldest.SetPlacement("north")
+
+	rules, _ := data.ParseMatchRules("name")
+	merged := data.MergeRepeatDonors([]*data.Patron{newest, other, oldest}, rules)
+	if len(merged) != 2 || merged[1] != oldest {
+		t.Fatal("For", "merged donors", "expected", "the first pledge last", "got", merged)
+	}
+	if oldest.PledgeAmt() != 100 || oldest.Message() != "For Mom" || oldest.Placement() != "north" {
+		t.Error(
+			"For", "merged donor",
+			"expected", "100 / For Mom / north",
+			"got", oldest.PledgeAmt(), oldest.Message(), oldest.Placement(),
+		)
+	}
+	if oldest.Payment() != data.PaymentPledged {
+		t.Error("For", "merged payment", "expected", data.PaymentPledged, "got", oldest.Payment())
+	}
+}
+
+func TestMergeFillsMissingDetails(t *testing.T) {
+	first := data.NewPatron(1, "2019-04-01 10:00:00", false, "Jane", "Smith", 50)
+	first.SetGift(data.NewGift("Sam Smith", false, false))
+	second := data.NewPatron(2, "2019-04-02 10:00:00", false, "Jane", "Smith", 50)
+	second.SetGift(data.NewGift("Sam Smith", true, false))
+	second.SetMessage("Happy birthday")
+	second.SetPlacement("cell 12")
+
+	rules, _ := data.ParseMatchRules("name")
+	merged := data.MergeRepeatDonors([]*data.Patron{second, first}, rules)
+	if len(merged) != 1 || merged[0] != first {
+		t.Fatal("For", "merged donors", "expected", "the first pledge", "got", merged)
+	}
+	if first.Message() != "Happy birthday" || first.Placement() != "cell 12" || !first.Gift().Anonymous() {
+		t.Error(
+			"For", "merged donor",
+			"expected", "Happy birthday / cell 12 / anonymous honoree",
+			"got", first.Message(), first.Placement(), first.Gift().Anonymous(),
+		)
+	}
+}
+
+func TestMergeRefusesConflicts(t *testing.T) {
+	tests := []struct {
+		message, placement, status string
+	}{
+		{"For Dad", "", ""},
+		{"", "south", ""},
+		{"", "", "refunded"},
+	}
+	for _, test := range tests {
+		first := data.NewPatron(1, "2019-04-01 10:00:00", false, "Jane", "Smith", 50)
+		first.SetMessage("For Mom")
+		first.SetPlacement("north")
+		second := data.NewPatron(2, "2019-04-02 10:00:00", false, "Jane", "Smith", 50)
+		if test.message != "" {
+			second.SetMessage(test.message)
+		}
+		second.SetPlacement(test.placement)
+		second.SetStatus(test.status)
+
+		rules, _ := data.ParseMatchRules("name")
+		if merged := data.MergeRepeatDonors([]*data.Patron{second, first}, rules); len(merged) != 2 {
+			t.Error("For", test, "expected", 2, "got", len(merged))
+		}
+	}
+}
+
+func TestParseMatchRulesRejectsUnknown(t *testing.T) {
+	if _, err := data.ParseMatchRules("email;shoe_size"); err == nil {
+		t.Error("expected an error for an unknown match attribute")
+	}
+}
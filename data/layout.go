@@ -0,0 +1,296 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FillOrder decides the order in which free positions on the array are handed
+// out to new adoptions.
+type FillOrder string
+
+const (
+	// FillRowMajor fills the array left to right, top to bottom.
+	FillRowMajor FillOrder = "row-major"
+	// FillSpiral fills the array from the center outwards.
+	FillSpiral FillOrder = "spiral"
+	// FillModule fills one module at a time in the order they're listed in the
+	// layout file, row-major within each module.
+	FillModule FillOrder = "module"
+)
+
+// ParseFillOrder converts a fill order name into a FillOrder.
+func ParseFillOrder(name string) (FillOrder, error) {
+	switch order := FillOrder(strings.ToLower(name)); order {
+	case FillRowMajor, FillSpiral, FillModule:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown fill order %q", name)
+}
+
+// Position is the physical location of a single solar cell on the array.
+type Position struct {
+	ID        int    `json:"id"`
+	Row       int    `json:"row"`
+	Col       int    `json:"col"`
+	Module    string `json:"module,omitempty"`
+	String    string `json:"string,omitempty"`
+	OffLimits bool   `json:"off_limits,omitempty"`
+
+	moduleIdx int
+}
+
+// moduleSpec describes a rectangular block of cells that make up one module.
+type moduleSpec struct {
+	Name   string `json:"name"`
+	String string `json:"string"`
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	Rows   int    `json:"rows"`
+	Cols   int    `json:"cols"`
+}
+
+// gridRef points at a single position on the array by row and column.
+type gridRef struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// region is a rectangle of positions on the array.
+type region struct {
+	Row  int `json:"row"`
+	Col  int `json:"col"`
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+// layoutFile is the on-disk format of a Layout. Modules are expanded into
+// cells, and any extra cells can be listed individually.
+type layoutFile struct {
+	Modules   []moduleSpec      `json:"modules"`
+	Cells     []Position        `json:"cells"`
+	OffLimits []gridRef         `json:"off_limits"`
+	Regions   map[string]region `json:"regions"`
+}
+
+// Layout is the geometry of the solar array.
+type Layout struct {
+	positions []*Position
+	byID      map[int]*Position
+	grid      map[gridRef]*Position
+	regions   map[string][]*Position
+}
+
+// LoadLayout reads the layout file at fileName. Cells generated from modules
+// are numbered in the order the modules are listed, row-major within each
+// module. Individually listed cells without an id are numbered after those.
+func LoadLayout(fileName string) (*Layout, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var file layoutFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	layout := &Layout{
+		byID:    make(map[int]*Position),
+		grid:    make(map[gridRef]*Position),
+		regions: make(map[string][]*Position),
+	}
+	nextID := 1
+	for idx, module := range file.Modules {
+		for row := 0; row < module.Rows; row++ {
+			for col := 0; col < module.Cols; col++ {
+				layout.positions = append(layout.positions, &Position{
+					ID:        nextID,
+					Row:       module.Row + row,
+					Col:       module.Col + col,
+					Module:    module.Name,
+					String:    module.String,
+					moduleIdx: idx,
+				})
+				nextID++
+			}
+		}
+	}
+	for i := range file.Cells {
+		pos := file.Cells[i]
+		if pos.ID == 0 {
+			pos.ID = nextID
+		}
+		pos.moduleIdx = len(file.Modules)
+		layout.positions = append(layout.positions, &pos)
+		if pos.ID >= nextID {
+			nextID = pos.ID + 1
+		}
+	}
+
+	grid := layout.grid
+	for _, pos := range layout.positions {
+		if _, dup := layout.byID[pos.ID]; dup {
+			return nil, fmt.Errorf("%s: cell %d is defined twice", fileName, pos.ID)
+		}
+		ref := gridRef{Row: pos.Row, Col: pos.Col}
+		if other, dup := grid[ref]; dup {
+			return nil, fmt.Errorf("%s: cells %d and %d share row %d, col %d", fileName, other.ID, pos.ID, pos.Row, pos.Col)
+		}
+		layout.byID[pos.ID] = pos
+		grid[ref] = pos
+	}
+
+	for _, ref := range file.OffLimits {
+		pos, ok := grid[ref]
+		if !ok {
+			return nil, fmt.Errorf("%s: off-limits cell at row %d, col %d is not on the array", fileName, ref.Row, ref.Col)
+		}
+		pos.OffLimits = true
+	}
+
+	// Every module is also a region, and named regions can be added on top.
+	for _, pos := range layout.Positions() {
+		if pos.Module != "" {
+			name := strings.ToLower(pos.Module)
+			layout.regions[name] = append(layout.regions[name], pos)
+		}
+	}
+	for name, area := range file.Regions {
+		var positions []*Position
+		for row := 0; row < area.Rows; row++ {
+			for col := 0; col < area.Cols; col++ {
+				if pos, ok := grid[gridRef{Row: area.Row + row, Col: area.Col + col}]; ok {
+					positions = append(positions, pos)
+				}
+			}
+		}
+		if len(positions) == 0 {
+			return nil, fmt.Errorf("%s: region %q has no cells", fileName, name)
+		}
+		layout.regions[strings.ToLower(name)] = positions
+	}
+
+	return layout, nil
+}
+
+// Positions returns every position on the array, including off-limits ones,
+// ordered by id.
+func (layout *Layout) Positions() []*Position {
+	positions := make([]*Position, len(layout.positions))
+	copy(positions, layout.positions)
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].ID < positions[j].ID
+	})
+	return positions
+}
+
+// Lookup returns the position with the given cell id.
+func (layout *Layout) Lookup(id int) (*Position, bool) {
+	pos, ok := layout.byID[id]
+	return pos, ok
+}
+
+// At returns the position at the given row and column.
+func (layout *Layout) At(row, col int) (*Position, bool) {
+	pos, ok := layout.grid[gridRef{Row: row, Col: col}]
+	return pos, ok
+}
+
+// Region returns the positions in the named region or module.
+func (layout *Layout) Region(name string) ([]*Position, bool) {
+	positions, ok := layout.regions[strings.ToLower(strings.TrimSpace(name))]
+	return positions, ok
+}
+
+// Order returns the positions that may be adopted, sorted by the fill order.
+func (layout *Layout) Order(fill FillOrder) []*Position {
+	var open []*Position
+	for _, pos := range layout.Positions() {
+		if !pos.OffLimits {
+			open = append(open, pos)
+		}
+	}
+
+	switch fill {
+	case FillSpiral:
+		layout.sortSpiral(open)
+	case FillModule:
+		sort.SliceStable(open, func(i, j int) bool {
+			a, b := open[i], open[j]
+			if a.moduleIdx != b.moduleIdx {
+				return a.moduleIdx < b.moduleIdx
+			}
+			return rowMajorLess(a, b)
+		})
+	default:
+		sort.SliceStable(open, func(i, j int) bool {
+			return rowMajorLess(open[i], open[j])
+		})
+	}
+
+	return open
+}
+
+// sortSpiral orders positions by ring around the center of the array, and
+// clockwise from the top within each ring.
+func (layout *Layout) sortSpiral(positions []*Position) {
+	if len(positions) == 0 {
+		return
+	}
+
+	minRow, maxRow := positions[0].Row, positions[0].Row
+	minCol, maxCol := positions[0].Col, positions[0].Col
+	for _, pos := range positions {
+		minRow, maxRow = minInt(minRow, pos.Row), maxInt(maxRow, pos.Row)
+		minCol, maxCol = minInt(minCol, pos.Col), maxInt(maxCol, pos.Col)
+	}
+	centerRow := float64(minRow+maxRow) / 2
+	centerCol := float64(minCol+maxCol) / 2
+
+	ring := func(pos *Position) float64 {
+		return math.Max(math.Abs(float64(pos.Row)-centerRow), math.Abs(float64(pos.Col)-centerCol))
+	}
+	angle := func(pos *Position) float64 {
+		// Rows grow downwards, so flip them to measure clockwise from the top.
+		a := math.Atan2(float64(pos.Col)-centerCol, centerRow-float64(pos.Row))
+		if a < 0 {
+			a += 2 * math.Pi
+		}
+		return a
+	}
+
+	sort.SliceStable(positions, func(i, j int) bool {
+		ri, rj := ring(positions[i]), ring(positions[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return angle(positions[i]) < angle(positions[j])
+	})
+}
+
+// rowMajorLess orders positions left to right, top to bottom.
+func rowMajorLess(a, b *Position) bool {
+	if a.Row != b.Row {
+		return a.Row < b.Row
+	}
+	return a.Col < b.Col
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
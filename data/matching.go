@@ -0,0 +1,278 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MatchTarget decides where a sponsor's matching funds go.
+type MatchTarget string
+
+const (
+	// MatchDonor adds the matched funds to the donor's own cell amount.
+	MatchDonor MatchTarget = "donor"
+	// MatchSponsor gives the matched funds to the sponsor as separate cells.
+	MatchSponsor MatchTarget = "sponsor"
+)
+
+// MatchingGift is a sponsor's offer to match pledges made inside a window, up
+// to a cap per donor and a cap overall.
+type MatchingGift struct {
+	Sponsor string `json:"sponsor"`
+	// Multiplier is how many dollars the sponsor gives per dollar pledged.
+	Multiplier float64 `json:"multiplier"`
+	// Start and End limit the pledges that are matched. Either may be left
+	// empty for an open-ended window.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// DonorCap and TotalCap are the most the sponsor gives in dollars to one
+	// donor and overall. Zero means no cap.
+	DonorCap float64 `json:"donor_cap,omitempty"`
+	TotalCap float64 `json:"total_cap,omitempty"`
+	// Apply is where the matched funds go, "donor" or "sponsor".
+	Apply MatchTarget `json:"apply"`
+
+	start, end time.Time
+}
+
+// matchCredit is the amount a sponsor matched for a Patron.
+// If applied is set, the amount was added to the Patron's cell amount.
+type matchCredit struct {
+	sponsor string
+	amount  float64
+	applied bool
+}
+
+// LoadMatchingGifts reads the matching gift rules in fileName.
+func LoadMatchingGifts(fileName string) ([]*MatchingGift, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var gifts []*MatchingGift
+	if err := json.Unmarshal(content, &gifts); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	for i, gift := range gifts {
+		if strings.TrimSpace(gift.Sponsor) == "" {
+			return nil, fmt.Errorf("%s: matching gift %d has no sponsor", fileName, i)
+		}
+		if gift.Multiplier <= 0 {
+			return nil, fmt.Errorf("%s: matching gift from %s needs a multiplier above 0", fileName, gift.Sponsor)
+		}
+		switch gift.Apply {
+		case "":
+			gift.Apply = MatchDonor
+		case MatchDonor, MatchSponsor:
+		default:
+			return nil, fmt.Errorf("%s: matching gift from %s has unknown target %q", fileName, gift.Sponsor, gift.Apply)
+		}
+		if gift.Start != "" {
+			if gift.start, err = time.Parse(timeLayout, gift.Start+" "+timeZone); err != nil {
+				return nil, fmt.Errorf("%s: matching gift from %s: %v", fileName, gift.Sponsor, err)
+			}
+		}
+		if gift.End != "" {
+			if gift.end, err = time.Parse(timeLayout, gift.End+" "+timeZone); err != nil {
+				return nil, fmt.Errorf("%s: matching gift from %s: %v", fileName, gift.Sponsor, err)
+			}
+		}
+	}
+
+	return gifts, nil
+}
+
+// covers reports whether a pledge made at t falls inside the gift's window.
+func (gift *MatchingGift) covers(t time.Time) bool {
+	if !gift.start.IsZero() && t.Before(gift.start) {
+		return false
+	}
+	return gift.end.IsZero() || !t.After(gift.end)
+}
+
+// Matched returns the total the sponsors matched for the Patron, in dollars.
+func (patron *Patron) Matched() float64 {
+	var total float64
+	for _, credit := range patron.matches {
+		total += credit.amount
+	}
+	return total
+}
+
+// matchingGifts works out what each gift matches, first come first served by
+// pledge time. Refunded and cancelled pledges aren't matched. Funds applied to
+// donors are added to their cell amounts, and a Patron is returned for every
+// sponsor whose funds become their own cells. A sponsor's pledges follow the
+// pledges they matched, so their cells are only as settled as the money they
+// matched.
+func matchingGifts(patrons []*Patron, gifts []*MatchingGift, nextID int) []*Patron {
+	type pledgeRef struct {
+		patron *Patron
+		pledge Pledge
+	}
+	var pledges []pledgeRef
+	for _, patron := range patrons {
+		if patron.withdrawn() {
+			continue
+		}
+		for _, pledge := range patron.pledges {
+			pledges = append(pledges, pledgeRef{patron, pledge})
+		}
+	}
+	sort.SliceStable(pledges, func(i, j int) bool {
+		return pledges[i].pledge.Time.Before(pledges[j].pledge.Time)
+	})
+
+	var sponsors []*Patron
+	for _, gift := range gifts {
+		var given float64
+		var first time.Time
+		byDonor := make(map[*Patron]float64)
+		var donors []*Patron
+		var matched []Pledge
+		var amounts []float64
+
+		for _, ref := range pledges {
+			if !gift.covers(ref.pledge.Time) || ref.pledge.Payment == PaymentFailed {
+				continue
+			}
+			amount := float64(ref.pledge.Amount) * gift.Multiplier
+			if gift.DonorCap > 0 && byDonor[ref.patron]+amount > gift.DonorCap {
+				amount = gift.DonorCap - byDonor[ref.patron]
+			}
+			if gift.TotalCap > 0 && given+amount > gift.TotalCap {
+				amount = gift.TotalCap - given
+			}
+			if amount <= 0 {
+				continue
+			}
+
+			if _, seen := byDonor[ref.patron]; !seen {
+				donors = append(donors, ref.patron)
+			}
+			if first.IsZero() {
+				first = ref.pledge.Time
+			}
+			byDonor[ref.patron] += amount
+			given += amount
+			matched = append(matched, Pledge{Time: ref.pledge.Time, Payment: ref.pledge.Payment})
+			amounts = append(amounts, amount)
+		}
+
+		for _, donor := range donors {
+			donor.matches = append(donor.matches, matchCredit{
+				sponsor: gift.Sponsor,
+				amount:  byDonor[donor],
+				applied: gift.Apply == MatchDonor,
+			})
+			if gift.Apply == MatchDonor {
+				donor.cellAmt += float32(byDonor[donor]) / float32(donor.cellPrice)
+			}
+		}
+
+		if gift.Apply == MatchSponsor && given > 0 {
+			names := strings.SplitN(strings.TrimSpace(gift.Sponsor), " ", 2)
+			names = append(names, "")
+			sponsor := NewPatron(nextID, first.Format("2006-01-02 15:04:05"), false, names[0], names[1], int(math.Round(given)))
+			sponsor.pledges = spreadMatch(matched, amounts)
+			sponsor.SetCellPrice(donors[0].cellPrice)
+			for _, donor := range donors {
+				sponsor.sponsorFor = append(sponsor.sponsorFor, donor.id)
+			}
+			sponsors = append(sponsors, sponsor)
+			nextID++
+		}
+	}
+
+	return sponsors
+}
+
+// spreadMatch sets the amount of each pledge a sponsor matched to the amount
+// it was matched with. The running total is rounded to whole dollars, so the
+// amounts add up to the rounded total. Pledges that round to nothing are left
+// out.
+func spreadMatch(pledges []Pledge, amounts []float64) []Pledge {
+	var spread []Pledge
+	var total float64
+	var rounded int
+	for i, pledge := range pledges {
+		total += amounts[i]
+		pledge.Amount = int(math.Round(total)) - rounded
+		rounded += pledge.Amount
+		if pledge.Amount > 0 {
+			spread = append(spread, pledge)
+		}
+	}
+	return spread
+}
+
+// MatchedBy returns the sponsors that matched the Patron's pledges.
+func (patron *Patron) MatchedBy() []string {
+	var sponsors []string
+	for _, credit := range patron.matches {
+		sponsors = append(sponsors, credit.sponsor)
+	}
+	return sponsors
+}
+
+// applyMatchingGifts works out the matching gifts for the PatronList and
+// returns a copy of it with any sponsor Patrons added and the totals updated
+// to include the matched funds. The PatronList itself is left untouched, so
+// it can be made into a CellList more than once without matching twice.
+func (patronList *PatronList) applyMatchingGifts(gifts []*MatchingGift) *PatronList {
+	if len(gifts) == 0 {
+		return patronList
+	}
+
+	matched := patronList.clone()
+	for _, sponsor := range matchingGifts(matched.patrons, gifts, matched.length+1) {
+		matched.AddPatron(sponsor)
+	}
+	for _, patron := range matched.patrons {
+		for _, credit := range patron.matches {
+			if credit.applied {
+				matched.totalRaised += int(credit.amount)
+				matched.totalCells += float32(credit.amount) / float32(patron.cellPrice)
+			}
+		}
+	}
+	return matched
+}
+
+// clone returns a copy of the PatronList holding copies of its Patrons, so
+// that working out matches doesn't change the caller's Patrons.
+func (patronList *PatronList) clone() *PatronList {
+	clone := *patronList
+	clone.patrons = make([]*Patron, len(patronList.patrons))
+	for i, patron := range patronList.patrons {
+		copied := *patron
+		copied.pledges = append([]Pledge(nil), patron.pledges...)
+		copied.matches = append([]matchCredit(nil), patron.matches...)
+		copied.sponsorFor = append([]int(nil), patron.sponsorFor...)
+		clone.patrons[i] = &copied
+	}
+	return &clone
+}
+
+// matchedCollected returns how much of the funds matched into the Patron's
+// cell amount count as collected. A match is only as settled as the pledges
+// it matched, so it's counted in proportion to what the donor has paid.
+func (patron *Patron) matchedCollected() float64 {
+	if patron.pledgeAmt <= 0 {
+		return 0
+	}
+	var applied float64
+	for _, credit := range patron.matches {
+		if credit.applied {
+			applied += credit.amount
+		}
+	}
+	return applied * float64(patron.collectedAmt()) / float64(patron.pledgeAmt)
+}
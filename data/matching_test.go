@@ -0,0 +1,158 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestMatchingGifts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matching")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rulesFile := path.Join(dir, "matching.json")
+	ioutil.WriteFile(rulesFile, []byte(`[
+		{"sponsor": "Acme Solar", "multiplier": 1, "donor_cap": 25, "end": "2019-04-01 00:00:00"},
+		{"sponsor": "Big Battery", "multiplier": 1, "total_cap": 100, "apply": "sponsor"}
+	]`), 0644)
+	gifts, err := data.LoadMatchingGifts(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+	john := data.NewPatron(2, "2019-04-02 10:00:00", false, "John", "Doe", 100)
+	list := data.NewCellList(data.NewPatronList([]*data.Patron{jane, john}), &data.CellOptions{MatchingGifts: gifts})
+	if jane.Matched() != 0 {
+		t.Error("For", "the caller's Patron", "expected", 0, "got", jane.Matched())
+	}
+	for _, patron := range list.Patrons() {
+		switch patron.SourceName() {
+		case "Jane Smith":
+			jane = patron
+		case "John Doe":
+			john = patron
+		}
+	}
+
+	// Jane is matched by both sponsors, John only by the one without a window.
+	if jane.Matched() != 75 || jane.CellAmt() != 1.5 {
+		t.Error("For", "Jane", "expected", "75 matched / 1.5 cells", "got", jane.Matched(), jane.CellAmt())
+	}
+	if john.Matched() != 50 || john.CellAmt() != 2 {
+		t.Error("For", "John", "expected", "50 matched / 2 cells", "got", john.Matched(), john.CellAmt())
+	}
+
+	var published struct {
+		Cells []struct {
+			MatchedBy []string `json:"matched_by"`
+			Matching  []int    `json:"matching_donor_ids"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	var matchedCells, sponsorCells int
+	for _, cell := range published.Cells {
+		if len(cell.MatchedBy) > 0 {
+			matchedCells++
+		}
+		if len(cell.Matching) == 2 {
+			sponsorCells++
+		}
+	}
+	if matchedCells != 1 || sponsorCells != 2 {
+		t.Error("For", "cells", "expected", "1 matched / 2 sponsor", "got", matchedCells, sponsorCells)
+	}
+}
+
+func TestMatchedCellsFollowPayment(t *testing.T) {
+	gifts := []*data.MatchingGift{{Sponsor: "Acme Solar", Multiplier: 1, Apply: data.MatchDonor}}
+	tests := []struct {
+		payment string
+		pending int
+	}{
+		{data.PaymentCollected, 0},
+		{data.PaymentPledged, 2},
+	}
+	for _, test := range tests {
+		jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+		jane.SetPayment(test.payment)
+		patronList := data.NewPatronList([]*data.Patron{jane})
+
+		// Building the list twice mustn't match the pledge twice.
+		data.NewCellList(patronList, &data.CellOptions{MatchingGifts: gifts})
+		out := data.NewCellList(patronList, &data.CellOptions{MatchingGifts: gifts}).String()
+
+		if cells := strings.Count(out, `"status": "`); cells != 2 {
+			t.Error("For", test.payment, "expected", "2 cells", "got", cells)
+		}
+		if pending := strings.Count(out, `"status": "pending"`); pending != test.pending {
+			t.Error("For", test.payment, "expected", test.pending, "pending", "got", pending)
+		}
+	}
+}
+
+func TestSponsorCellsFollowPayment(t *testing.T) {
+	gifts := []*data.MatchingGift{{Sponsor: "Big Battery", Multiplier: 1.005, Apply: data.MatchSponsor}}
+	jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100)
+	john := data.NewPatron(2, "2019-04-02 10:00:00", false, "John", "Doe", 100)
+	john.SetPayment(data.PaymentPledged)
+	list := data.NewCellList(data.NewPatronList([]*data.Patron{jane, john}), &data.CellOptions{MatchingGifts: gifts})
+
+	var sponsor *data.Patron
+	for _, patron := range list.Patrons() {
+		if patron.SourceName() == "Big Battery" {
+			sponsor = patron
+		}
+	}
+	if sponsor == nil {
+		t.Fatal("For", "Big Battery", "expected", "a sponsor", "got", nil)
+	}
+
+	// $100.50 matched twice rounds to $201, spread over the matched pledges.
+	pledges := sponsor.Pledges()
+	if sponsor.PledgeAmt() != 201 || len(pledges) != 2 || pledges[0].Amount+pledges[1].Amount != 201 {
+		t.Error("For", "sponsor pledges", "expected", "$201 over 2 pledges", "got", sponsor.PledgeAmt(), pledges)
+	}
+	if !pledges[1].Time.Equal(john.PledgeTime()) || pledges[1].Payment != data.PaymentPledged {
+		t.Error("For", "John's match", "expected", "pledged at his pledge time", "got", pledges[1])
+	}
+
+	// Half the match is still owed, so half the sponsor's cells are pending.
+	var published struct {
+		Cells []struct {
+			Status   string `json:"status"`
+			Matching []int  `json:"matching_donor_ids"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	var sponsorCells, pending int
+	for _, cell := range published.Cells {
+		if len(cell.Matching) == 0 {
+			continue
+		}
+		sponsorCells++
+		if cell.Status == data.CellPending {
+			pending++
+		}
+	}
+	if sponsorCells != 4 || pending != 2 {
+		t.Error("For", "sponsor cells", "expected", "4 / 2 pending", "got", sponsorCells, pending)
+	}
+
+	// The match isn't one of the campaign's own pledges.
+	if pledges := list.Pledges(); len(pledges) != 2 {
+		t.Error("For", "Pledges()", "expected", 2, "got", len(pledges))
+	}
+}
@@ -8,21 +8,35 @@ import (
 	"github.com/iAmSomeone2/aacautoupdate/logging"
 )
 
-var replacementPairs = []string{
-	"\"\"", " ", // Double quotes are replaced with a single space.
-	"var data = ", "", // "var data = " is removed.
-	"\"", "", // Individual quotation marks are removed.
-	";", "", // Trailing semicolon removed.
-}
-
 const (
 	lineDelim  string = "],["
 	valueDelim string = ","
 )
 
+// dedicationHeaders lists the column headings that may hold a dedication
+// message in the export. The first one found is used.
+var dedicationHeaders = []string{"dedication", "message", "comment", "comments"}
+
+// statusHeaders lists the column headings that may hold the pledge status.
+var statusHeaders = []string{"status", "pledge status"}
+
+// contactHeaders maps each contact attribute used for identity matching or
+// shipping rewards to the column headings that may hold it.
+var contactHeaders = map[string][]string{
+	"email":    {"email", "e-mail", "email address"},
+	"phone":    {"phone", "phone number"},
+	"zip":      {"zip", "zip code", "postal code"},
+	"address":  {"address", "street address", "shipping address", "address 1", "address line 1"},
+	"address2": {"address 2", "address line 2"},
+	"city":     {"city", "town"},
+	"state":    {"state", "province"},
+	"country":  {"country"},
+}
+
 // Clean reads the data from the file which the fileName argument is pointing to
-// and places it into a string for initial processing. Extraneous characters and
-// formatting is removed before the string is returned.
+// and places it into a string for initial processing. The surrounding script is
+// removed before the string is returned, but the values keep their quotes so
+// that commas in messages don't split them.
 func Clean(fileName string) (string, error) {
 	var cleanStr string
 	// Read the file into memory and and assign it's data to a string for processing.
@@ -45,9 +59,9 @@ func formatForUsage(content string) string {
 	// First remove extraneous spaces
 	result = strings.TrimSpace(content)
 
-	// Next, build a replacer that will format our string
-	replacer := strings.NewReplacer(replacementPairs...)
-	result = replacer.Replace(result)
+	// Next, remove the variable declaration and the trailing semicolon.
+	result = strings.TrimPrefix(result, "var data = ")
+	result = strings.TrimSpace(strings.TrimSuffix(result, ";"))
 
 	return result
 }
@@ -58,7 +72,26 @@ func GetPatronData(rawData string) []*Patron {
 	logger := logging.NewLogger()
 	var patrons []*Patron
 	// First, split the data into a 1D slice of strings using lineDelim
-	lineData := strings.Split(rawData, lineDelim)
+	lineData := splitUnquoted(rawData, lineDelim)
+
+	// The first line holds the headings. Optional columns are found by name.
+	header := parseHeader(lineData[0])
+	dedicationIdx := findColumn(header, dedicationHeaders)
+	honoreeIdx := findColumn(header, honoreeHeaders)
+	honoreeAnonIdx := findColumn(header, honoreeAnonHeaders)
+	hideGiverIdx := findColumn(header, hideGiverHeaders)
+	statusIdx := findColumn(header, statusHeaders)
+	placementIdx := findColumn(header, placementHeaders)
+	subscriptionIdx := findColumn(header, subscriptionHeaders)
+	paymentIdx := findColumn(header, paymentHeaders)
+	contactIdx := make(map[string]int)
+	for attr, names := range contactHeaders {
+		contactIdx[attr] = findColumn(header, names)
+	}
+	attributionIdx := make(map[string]int)
+	for attr, names := range attributionHeaders {
+		attributionIdx[attr] = findColumn(header, names)
+	}
 
 	// For each line, split the data using valueDelim
 	for i, line := range lineData {
@@ -67,25 +100,140 @@ func GetPatronData(rawData string) []*Patron {
 			continue
 		}
 
-		values := strings.Split(line, valueDelim)
+		values := splitValues(line)
 
 		// Grab the values we need.
-		pledgeTime := values[timePledgedIdx]
-		anon := values[anonValIdx] == "yes"
-		name := strings.Split(values[fNameValIdx], " ")
+		pledgeTime := column(values, timePledgedIdx)
+		anon := column(values, anonValIdx) == "yes"
+		name := strings.SplitN(column(values, fNameValIdx)+" ", " ", 2)
 		fName := name[0]
-		lName := name[1]
+		lName := strings.TrimSpace(name[1])
 
-		pledgeAmt, err := strconv.Atoi(values[pledgeValIdx])
+		pledgeAmt, err := strconv.Atoi(column(values, pledgeValIdx))
 		if err != nil {
 			logger.Panic(err)
 		}
 
-		patrons = append(patrons, NewPatron(i, pledgeTime, anon, fName, lName, pledgeAmt))
+		patron := NewPatron(i, pledgeTime, anon, fName, lName, pledgeAmt)
+		if message := column(values, dedicationIdx); message != "" {
+			patron.SetMessage(message)
+		}
+		patron.SetStatus(strings.ToLower(column(values, statusIdx)))
+		patron.SetPlacement(column(values, placementIdx))
+		patron.SetSubscription(column(values, subscriptionIdx))
+		patron.SetPayment(column(values, paymentIdx))
+		for attr, idx := range contactIdx {
+			if value := column(values, idx); value != "" {
+				patron.contact[attr] = value
+			}
+		}
+		attribution := make(map[string]string)
+		for attr, idx := range attributionIdx {
+			attribution[attr] = column(values, idx)
+		}
+		patron.SetChannel(Channel(attribution), Tracked(attribution))
+		if honoree := column(values, honoreeIdx); honoree != "" {
+			patron.SetGift(NewGift(
+				honoree,
+				parseYes(column(values, honoreeAnonIdx)),
+				parseYes(column(values, hideGiverIdx)),
+			))
+		}
+
+		patrons = append(patrons, patron)
 	}
 	return patrons
 }
 
+// parseHeader maps each lowercased column heading in line to its index.
+func parseHeader(line string) map[string]int {
+	header := make(map[string]int)
+	for i, heading := range splitValues(line) {
+		heading = strings.ToLower(heading)
+		if _, exists := header[heading]; !exists {
+			header[heading] = i
+		}
+	}
+	return header
+}
+
+// findColumn returns the index of the first heading in names that exists in
+// header, or -1 if none of them do.
+func findColumn(header map[string]int, names []string) int {
+	for _, name := range names {
+		if idx, ok := header[name]; ok {
+			return idx
+		}
+	}
+	return -1
+}
+
+// column safely returns the value at idx. An empty string is returned if the
+// column is missing from the row.
+func column(values []string, idx int) string {
+	if idx < 0 || idx >= len(values) {
+		return ""
+	}
+	return values[idx]
+}
+
+// splitUnquoted splits s around each instance of sep that isn't inside a
+// quoted value.
+func splitUnquoted(s string, sep string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++ // The next character is escaped.
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitValues splits one line of the export into its values. Quoted values
+// are unquoted, and the brackets around the first and last lines are removed.
+func splitValues(line string) []string {
+	values := splitUnquoted(line, valueDelim)
+	for i, value := range values {
+		values[i] = unquote(value)
+	}
+	return values
+}
+
+// unquote returns value without its surrounding brackets and quotes. Quotes
+// inside a quoted value may be escaped with a backslash or by doubling them.
+func unquote(value string) string {
+	value = strings.TrimLeft(strings.TrimSpace(value), "[")
+	if !strings.HasPrefix(value, "\"") {
+		return strings.TrimSpace(strings.TrimRight(value, "]"))
+	}
+
+	var text strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			i++
+			text.WriteByte(value[i])
+		case value[i] == '"' && i+1 < len(value) && value[i+1] == '"':
+			i++
+			text.WriteByte('"')
+		case value[i] == '"':
+			return strings.TrimSpace(text.String())
+		default:
+			text.WriteByte(value[i])
+		}
+	}
+	return strings.TrimSpace(text.String())
+}
+
 // ToJSONFile exports the contents of a PatronList to a JSON file.
 func (patronList *PatronList) ToJSONFile(fileName string) error {
 
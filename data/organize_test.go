@@ -0,0 +1,79 @@
+package data_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// quotedLine builds one quoted line of a supporters export with the time,
+// anonymous flag, name and amount in their fixed columns and extra values after
+// them.
+func quotedLine(pledgeTime, anon, name, amount string, extra ...string) string {
+	values := make([]string, 33)
+	values[0], values[2], values[5], values[32] = pledgeTime, anon, name, amount
+	var quoted []string
+	for _, value := range append(values, extra...) {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
+func TestQuotedExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "organize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exportFile := path.Join(dir, "supporters.js")
+	ioutil.WriteFile(exportFile, []byte("var data = ["+strings.Join([]string{
+		quotedLine("Time", "Anonymous", "Name", "Amount", "Dedication", "City"),
+		quotedLine("2019-03-31 08:21:16", "no", "Jane Smith", "100", "For Mom, Dad, and Sam", "Austin"),
+		quotedLine("2019-04-01 10:00:00", "yes", "John Doe", "50", `He said "go solar"; so we did],[`, "Dallas"),
+		quotedLine("2019-04-02 10:00:00", "no", "Cher", "25", "", "Waco"),
+	}, ",")+"];\n"), 0644)
+
+	cleanData, err := data.Clean(exportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patrons := data.GetPatronData(cleanData)
+	if len(patrons) != 3 {
+		t.Fatal("For", "patrons", "expected", 3, "got", len(patrons))
+	}
+
+	tests := []struct {
+		name    string
+		amount  int
+		message string
+		city    string
+	}{
+		{"Jane Smith", 100, "For Mom, Dad, and Sam", "Austin"},
+		{"John Doe", 50, `He said "go solar"; so we did],[`, "Dallas"},
+		{"Cher", 25, "", "Waco"},
+	}
+	for i, test := range tests {
+		patron := patrons[i]
+		if name := patron.SourceName(); name != test.name {
+			t.Error("For", i, "expected", test.name, "got", name)
+		}
+		if patron.PledgeAmt() != test.amount {
+			t.Error("For", test.name, "expected", test.amount, "got", patron.PledgeAmt())
+		}
+		if patron.Message() != test.message {
+			t.Error("For", test.name, "expected", test.message, "got", patron.Message())
+		}
+		if city := patron.Contact("city"); city != test.city {
+			t.Error("For", test.name, "expected", test.city, "got", city)
+		}
+	}
+	if !patrons[1].Anonymous() {
+		t.Error("For", "John Doe", "expected", "anonymous", "got", "public")
+	}
+}
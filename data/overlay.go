@@ -0,0 +1,96 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// OverlayEntry holds extra information about a pledge that the campaign export
+// doesn't provide. Entries are matched to patrons by name, and optionally by
+// pledge time when a donor has made more than one pledge.
+type OverlayEntry struct {
+	Name       string `json:"name"`
+	PledgeTime string `json:"pledge_time,omitempty"`
+	Dedication string `json:"dedication,omitempty"`
+
+	// Honoree turns the pledge into a gift adoption for the named person.
+	Honoree          string `json:"honoree,omitempty"`
+	HonoreeAnonymous bool   `json:"honoree_anonymous,omitempty"`
+	HideGiver        bool   `json:"hide_giver,omitempty"`
+
+	// Placement asks for a spot on the array, such as "cell 112", a region
+	// name or "next to Jane Smith".
+	Placement string `json:"placement,omitempty"`
+
+	pledgeTime time.Time
+}
+
+// Overlay is a list of OverlayEntries loaded from a JSON file.
+type Overlay struct {
+	entries []OverlayEntry
+}
+
+// LoadOverlay reads the overlay file at fileName. A missing file is treated as
+// an empty Overlay.
+func LoadOverlay(fileName string) (*Overlay, error) {
+	overlay := &Overlay{}
+
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return overlay, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &overlay.entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	// Parse the pledge times up front so that typos are reported right away.
+	for i := range overlay.entries {
+		entry := &overlay.entries[i]
+		if entry.PledgeTime == "" {
+			continue
+		}
+		entry.pledgeTime, err = time.Parse(timeLayout, entry.PledgeTime+" "+timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %v", fileName, i, err)
+		}
+	}
+
+	return overlay, nil
+}
+
+// Apply copies the overlay values onto every matching Patron. Values from the
+// overlay take priority over anything that came from the export.
+func (overlay *Overlay) Apply(patrons []*Patron) {
+	for _, entry := range overlay.entries {
+		for _, patron := range patrons {
+			if !entry.matches(patron) {
+				continue
+			}
+			if entry.Dedication != "" {
+				patron.SetMessage(entry.Dedication)
+			}
+			if entry.Placement != "" {
+				patron.SetPlacement(entry.Placement)
+			}
+			if entry.Honoree != "" {
+				patron.SetGift(NewGift(entry.Honoree, entry.HonoreeAnonymous, entry.HideGiver))
+			}
+		}
+	}
+}
+
+// matches reports whether the entry refers to the given Patron.
+func (entry OverlayEntry) matches(patron *Patron) bool {
+	if !strings.EqualFold(strings.TrimSpace(entry.Name), patron.sourceName) {
+		return false
+	}
+	return entry.PledgeTime == "" || entry.pledgeTime.Equal(patron.pledgeTime)
+}
@@ -0,0 +1,90 @@
+package data_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestOverlayApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	overlayFile := path.Join(dir, "overlay.json")
+	ioutil.WriteFile(overlayFile, []byte(`[
+		{"name": "jane smith", "dedication": "For the kids"},
+		{"name": "John Doe", "pledge_time": "2019-04-02 10:00:00", "placement": "cell 12"},
+		{"name": " Ann Lee ", "dedication": "From the overlay", "placement": "north"}
+	]`), 0644)
+	overlay, err := data.LoadOverlay(overlayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ann := data.NewPatron(4, "2019-04-03 10:00:00", false, "Ann", "Lee", 50)
+	ann.SetMessage("From the export")
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 50),
+		data.NewPatron(3, "2019-04-02 10:00:00", false, "John", "Doe", 50),
+		ann,
+		data.NewPatron(5, "2019-04-04 10:00:00", false, "Sam", "Jones", 50),
+	}
+	overlay.Apply(patrons)
+
+	tests := []struct {
+		message   string
+		placement string
+	}{
+		{"For the kids", ""},
+		{"", ""},
+		{"", "cell 12"},
+		{"From the overlay", "north"},
+		{"", ""},
+	}
+	for i, test := range tests {
+		patron := patrons[i]
+		if patron.Message() != test.message || patron.Placement() != test.placement {
+			t.Error(
+				"For", patron.SourceName(), patron.PledgeTime(),
+				"expected", test.message, test.placement,
+				"got", patron.Message(), patron.Placement(),
+			)
+		}
+	}
+}
+
+func TestLoadOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		valid   bool
+	}{
+		{"", true},
+		{`[]`, true},
+		{`[{"name": "Jane Smith", "pledge_time": "2019-03-31 08:21:16"}]`, true},
+		{`[{"name": "Jane Smith", "pledge_time": "March 31st"}]`, false},
+		{`{"name": "Jane Smith"}`, false},
+	}
+	for _, test := range tests {
+		overlayFile := path.Join(dir, "overlay.json")
+		os.Remove(overlayFile)
+		if test.content != "" {
+			ioutil.WriteFile(overlayFile, []byte(test.content), 0644)
+		}
+		if _, err := data.LoadOverlay(overlayFile); (err == nil) != test.valid {
+			t.Error("For", test.content, "expected", test.valid, "got", err)
+		}
+	}
+}
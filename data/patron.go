@@ -8,19 +8,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
 // Patron provides a structure for storing the values needed for updating the
 // JSON file that the web app reads from.
 type Patron struct {
-	id         int
-	pledgeTime time.Time
-	anonymous  bool
-	firstName  string
-	lastName   string
-	pledgeAmt  int
-	cellAmt    float32
+	id           int
+	pledgeTime   time.Time
+	anonymous    bool
+	pending      bool
+	sourceName   string
+	firstName    string
+	lastName     string
+	pledgeAmt    int
+	cellAmt      float32
+	cellPrice    int
+	message      string
+	msgState     string
+	gift         *Gift
+	contact      map[string]string
+	pledges      []Pledge
+	status       string
+	placement    string
+	subscription string
+	matches      []matchCredit
+	sponsorFor   []int
+	carried      bool
+}
+
+// Pledge is a single pledge from the export. A Patron holds more than one
+// Pledge when repeat donations have been merged together.
+type Pledge struct {
+	Time    time.Time `json:"time"`
+	Amount  int       `json:"amount"`
+	Payment string    `json:"payment,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Tracked bool      `json:"tracked,omitempty"`
 }
 
 const (
@@ -29,7 +54,26 @@ const (
 	timePledgedIdx int = 0
 	pledgeValIdx   int = 32
 
-	cellCost int = 50
+	// DefaultCellPrice is the price of a single cell in dollars unless a
+	// campaign sets its own.
+	DefaultCellPrice int = 50
+
+	// PledgeActive marks a pledge in good standing.
+	PledgeActive string = "active"
+	// PledgeRefunded marks a pledge that was paid and then refunded.
+	PledgeRefunded string = "refunded"
+	// PledgeCancelled marks a pledge that was withdrawn before payment or has
+	// disappeared from the export.
+	PledgeCancelled string = "cancelled"
+	// PledgePending marks a pledge the platform hasn't confirmed yet.
+	PledgePending string = "pending"
+
+	// MessagePending marks a dedication that is waiting for review.
+	MessagePending string = "pending"
+	// MessageApproved marks a dedication that may be published.
+	MessageApproved string = "approved"
+	// MessageRejected marks a dedication that must not be published.
+	MessageRejected string = "rejected"
 
 	// Time used in this string must equate to Jan 2 15:04:05 MST 2006
 	// Example time from patron data: 2019-03-31 08:21:16
@@ -41,7 +85,8 @@ const (
 // function is called. cellAmt is computed based on the pledge amount and may be
 // any floating point value greater than 0.
 func NewPatron(id int, pledgeTime string, anon bool, fName, lName string, pledgeAmt int) *Patron {
-	cellNum := float32(pledgeAmt) / float32(cellCost)
+	cellNum := float32(pledgeAmt) / float32(DefaultCellPrice)
+	sourceName := strings.TrimSpace(fName + " " + lName)
 
 	if anon {
 		fName = "Anonymous"
@@ -60,10 +105,15 @@ func NewPatron(id int, pledgeTime string, anon bool, fName, lName string, pledge
 		id:         id,
 		pledgeTime: parsedTime,
 		anonymous:  anon,
+		sourceName: sourceName,
 		firstName:  fName,
 		lastName:   lName,
 		pledgeAmt:  pledgeAmt,
 		cellAmt:    cellNum,
+		cellPrice:  DefaultCellPrice,
+		contact:    make(map[string]string),
+		pledges:    []Pledge{{Time: parsedTime, Amount: pledgeAmt}},
+		status:     PledgeActive,
 	}
 }
 
@@ -96,6 +146,13 @@ func (patron Patron) MarshalJSON() ([]byte, error) {
 	}
 	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "anonymous", string(anonJSON)))
 
+	// pending_review field
+	pendingJSON, err := json.Marshal(patron.pending)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "pending_review", string(pendingJSON)))
+
 	// first_name field
 	fNameJSON, err := json.Marshal(patron.firstName)
 	if err != nil {
@@ -117,17 +174,189 @@ func (patron Patron) MarshalJSON() ([]byte, error) {
 	}
 	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "pledge_amt", string(pledgeJSON)))
 
+	// pledge_count field
+	countJSON, err := json.Marshal(len(patron.pledges))
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "pledge_count", string(countJSON)))
+
 	// cell_num field
 	cellJSON, err := json.Marshal(patron.cellAmt)
 	if err != nil {
 		return nil, err
 	}
-	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "cell_amt", string(cellJSON)))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "cell_amt", string(cellJSON)))
+
+	// honoree field
+	var honoree string
+	if patron.gift != nil {
+		honoree = patron.gift.DisplayName()
+	}
+	honoreeJSON, err := json.Marshal(honoree)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "honoree", string(honoreeJSON)))
+
+	// recurring field
+	buffer.WriteString(fmt.Sprintf("\"%s\":%t", "recurring", patron.subscription != ""))
+
+	// matched_amount and matched_by fields, only used when a sponsor matched
+	// the pledge
+	if len(patron.matches) > 0 {
+		sponsorsJSON, err := json.Marshal(patron.MatchedBy())
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf(",\"%s\":%.2f", "matched_amount", patron.Matched()))
+		buffer.WriteString(fmt.Sprintf(",\"%s\":%s", "matched_by", string(sponsorsJSON)))
+	}
+
+	// matching_donor_ids field, only used by sponsors of a matching gift
+	if len(patron.sponsorFor) > 0 {
+		donorsJSON, err := json.Marshal(patron.sponsorFor)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf(",\"%s\":%s", "matching_donor_ids", string(donorsJSON)))
+	}
 
 	buffer.WriteString("}")
 	return buffer.Bytes(), nil
 }
 
+// Name returns the full name of the Patron as it will be published.
+func (patron *Patron) Name() string {
+	return strings.TrimSpace(patron.firstName + " " + patron.lastName)
+}
+
+// Key identifies the Patron across runs. It's built from the name in the export
+// and the time of the first pledge, so it survives reordering of the export.
+func (patron *Patron) Key() string {
+	return strings.ToLower(patron.sourceName) + "@" + patron.pledgeTime.Format("2006-01-02 15:04:05")
+}
+
+// SourceName returns the Patron's name exactly as it appeared in the export,
+// even if the Patron is anonymous. It must never be published.
+func (patron *Patron) SourceName() string {
+	return patron.sourceName
+}
+
+// Contact returns the Patron's contact detail for attr, such as "email" or
+// "city", or an empty string if the export doesn't provide it. Like the
+// source name, it must never be published.
+func (patron *Patron) Contact(attr string) string {
+	return patron.contact[attr]
+}
+
+// Contacts returns a copy of every contact detail the export provided for the
+// Patron. Like the source name, they must never be published.
+func (patron *Patron) Contacts() map[string]string {
+	contacts := make(map[string]string, len(patron.contact))
+	for attr, value := range patron.contact {
+		contacts[attr] = value
+	}
+	return contacts
+}
+
+// SetContact records the Patron's contact detail for attr.
+func (patron *Patron) SetContact(attr, value string) {
+	patron.contact[attr] = strings.TrimSpace(value)
+}
+
+// PledgeTime returns the time the pledge was made.
+func (patron *Patron) PledgeTime() time.Time {
+	return patron.pledgeTime
+}
+
+// PledgeAmt returns the total amount the Patron has pledged.
+func (patron *Patron) PledgeAmt() int {
+	return patron.pledgeAmt
+}
+
+// CellAmt returns the number of cells the Patron's pledges cover.
+func (patron *Patron) CellAmt() float32 {
+	return patron.cellAmt
+}
+
+// SetCellPrice changes the price of a cell for the Patron and works out their
+// cell amount again.
+func (patron *Patron) SetCellPrice(price int) {
+	patron.cellPrice = price
+	patron.cellAmt = float32(patron.pledgeAmt) / float32(price)
+}
+
+// Pledges returns the individual pledges that make up the Patron's total.
+func (patron *Patron) Pledges() []Pledge {
+	return patron.pledges
+}
+
+// Status returns the state of the Patron's pledge.
+func (patron *Patron) Status() string {
+	return patron.status
+}
+
+// withdrawn reports whether the Patron's pledge was refunded or cancelled. The
+// refund policy may still keep its cells, but it's no longer money raised.
+func (patron *Patron) withdrawn() bool {
+	return patron.status == PledgeRefunded || patron.status == PledgeCancelled
+}
+
+// SetStatus records the state of the Patron's pledge. Unrecognized values are
+// treated as active.
+func (patron *Patron) SetStatus(status string) {
+	switch status {
+	case PledgeRefunded, PledgeCancelled, PledgePending:
+		patron.status = status
+	default:
+		patron.status = PledgeActive
+	}
+}
+
+// Message returns the Patron's dedication message, if one was given.
+func (patron *Patron) Message() string {
+	return patron.message
+}
+
+// MessageState returns the moderation state of the Patron's dedication.
+func (patron *Patron) MessageState() string {
+	return patron.msgState
+}
+
+// SetMessage sets the Patron's dedication message. New messages always start
+// out pending until they've been through moderation.
+func (patron *Patron) SetMessage(text string) {
+	patron.message = strings.TrimSpace(text)
+	patron.msgState = MessagePending
+}
+
+// SetMessageState records the moderation state of the Patron's dedication.
+func (patron *Patron) SetMessageState(state string) {
+	patron.msgState = state
+}
+
+// Anonymous reports whether the Patron asked to be listed anonymously.
+func (patron *Patron) Anonymous() bool {
+	return patron.anonymous
+}
+
+// HoldForReview replaces the Patron's name with a placeholder until an
+// operator has approved it for publishing.
+func (patron *Patron) HoldForReview() {
+	patron.pending = true
+	patron.firstName = "Pending"
+	patron.lastName = "Review"
+}
+
+// Redact replaces the Patron's name the same way an anonymous pledge would be.
+// This is used for names that an operator has rejected.
+func (patron *Patron) Redact() {
+	patron.pending = false
+	patron.firstName = "Anonymous"
+	patron.lastName = "Donor"
+}
+
 // String returns the values contained in a Patron struct formatted so that
 // it makes sense to read.
 func (patron *Patron) String() string {
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path"
+	"time"
 )
 
 // PatronList is a struct used for managing a list of Patrons.
@@ -13,27 +17,38 @@ type PatronList struct {
 	length      int
 	totalRaised int
 	totalCells  float32
+	carriedIn   int
+	withdrawn   int
 }
 
 // NewPatronList constructs a PatronList and returns a
 // pointer to it. Only a slice of Patrons is required. All
 // other values are computed from the list.
 func NewPatronList(newPatrons []*Patron) *PatronList {
-	var amtRaised int
-	var cellNum float32
-	patronNum := len(newPatrons)
-
+	patronList := &PatronList{
+		patrons: reverse(newPatrons),
+		length:  len(newPatrons),
+	}
 	for _, patron := range newPatrons {
-		amtRaised += patron.pledgeAmt
-		cellNum += patron.cellAmt
+		patronList.addTotals(patron)
 	}
+	return patronList
+}
 
-	return &PatronList{
-		patrons:     reverse(newPatrons),
-		length:      patronNum,
-		totalRaised: amtRaised,
-		totalCells:  cellNum,
+// addTotals adds the Patron's pledge to the totals. Credit carried in from an
+// earlier campaign was already counted there, and refunded or cancelled pledges
+// were never kept, so both are kept apart from the money raised by this one.
+func (patronList *PatronList) addTotals(patron *Patron) {
+	if patron.carried {
+		patronList.carriedIn += patron.pledgeAmt
+		return
+	}
+	if patron.withdrawn() {
+		patronList.withdrawn += patron.pledgeAmt
+		return
 	}
+	patronList.totalRaised += patron.pledgeAmt
+	patronList.totalCells += patron.cellAmt
 }
 
 // AddPatron appends a new Patron to the PatronList and update all struct values to reflect this change.
@@ -43,8 +58,12 @@ func (patronList *PatronList) AddPatron(newPatron *Patron) {
 
 	// Update the remaining values.
 	patronList.length += 1
-	patronList.totalRaised += newPatron.pledgeAmt
-	patronList.totalCells += newPatron.cellAmt
+	patronList.addTotals(newPatron)
+}
+
+// Patrons returns the Patrons in the list.
+func (patronList *PatronList) Patrons() []*Patron {
+	return patronList.patrons
 }
 
 // Reverse flips the order in which Patrons are stored in a []*Patron
@@ -99,7 +118,21 @@ func (patronList PatronList) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "total_cells", string(cellsJSON)))
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "total_cells", string(cellsJSON)))
+
+	// carried_in field
+	carriedJSON, err := json.Marshal(patronList.carriedIn)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s,", "carried_in", string(carriedJSON)))
+
+	// withdrawn field
+	withdrawnJSON, err := json.Marshal(patronList.withdrawn)
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString(fmt.Sprintf("\"%s\":%s", "withdrawn", string(withdrawnJSON)))
 
 	buffer.WriteRune('}')
 	// fmt.Println(string(buffer.Bytes()))
@@ -116,3 +149,50 @@ func (patronList *PatronList) String() string {
 
 	return string(jsonStr)
 }
+
+// privatePatron is the full record of a Patron used in the private output. It
+// includes contact details and the pledge history, so it must never be
+// written anywhere the web server can reach.
+type privatePatron struct {
+	ID           int               `json:"id"`
+	Name         string            `json:"name"`
+	Anonymous    bool              `json:"anonymous"`
+	Contact      map[string]string `json:"contact,omitempty"`
+	PledgeAmt    int               `json:"pledge_amt"`
+	CellAmt      float32           `json:"cell_amt"`
+	FirstPledge  time.Time         `json:"first_pledge"`
+	Pledges      []Pledge          `json:"pledges"`
+	Subscription string            `json:"subscription,omitempty"`
+}
+
+// ToPrivateJSONFile writes every Patron in the list, including their contact
+// details and individual pledges, to fileName.
+func (patronList *PatronList) ToPrivateJSONFile(fileName string) error {
+	err := os.MkdirAll(path.Dir(fileName), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	records := make([]privatePatron, 0, len(patronList.patrons))
+	for _, patron := range patronList.patrons {
+		records = append(records, privatePatron{
+			ID:           patron.id,
+			Name:         patron.sourceName,
+			Anonymous:    patron.anonymous,
+			Contact:      patron.contact,
+			PledgeAmt:    patron.pledgeAmt,
+			CellAmt:      patron.cellAmt,
+			FirstPledge:  patron.pledgeTime,
+			Pledges:      patron.pledges,
+			Subscription: patron.subscription,
+		})
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Only the service user should be able to read this file.
+	return ioutil.WriteFile(fileName, data, 0600)
+}
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PaymentPledged marks a pledge that hasn't been charged yet.
+	PaymentPledged string = "pledged"
+	// PaymentCollected marks a pledge whose money has been received.
+	PaymentCollected string = "collected"
+	// PaymentFailed marks a pledge whose charge was declined or reversed.
+	PaymentFailed string = "failed"
+
+	// CellPending is the status of a cell funded by money that hasn't been
+	// collected yet.
+	CellPending string = "pending"
+)
+
+// paymentHeaders lists the column headings that may hold a pledge's payment
+// status.
+var paymentHeaders = []string{"payment status", "payment", "collection status"}
+
+// parsePayment converts a payment status from an export or reconciliation
+// file into one of the Payment constants. Anything unrecognized is left empty,
+// which is treated as collected.
+func parsePayment(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case PaymentPledged, "pending", "authorized", "uncollected":
+		return PaymentPledged
+	case PaymentCollected, "paid", "charged", "complete", "completed":
+		return PaymentCollected
+	case PaymentFailed, "declined", "chargeback", "reversed":
+		return PaymentFailed
+	}
+	return ""
+}
+
+// SetPayment records the payment status of every pledge the Patron holds.
+func (patron *Patron) SetPayment(status string) {
+	for i := range patron.pledges {
+		patron.pledges[i].Payment = parsePayment(status)
+	}
+}
+
+// Payment returns the least settled payment status of the Patron's pledges:
+// failed, then pledged, then collected. Pledges without a known status count
+// as collected.
+func (patron *Patron) Payment() string {
+	payment := PaymentCollected
+	for _, pledge := range patron.pledges {
+		switch pledge.Payment {
+		case PaymentFailed:
+			return PaymentFailed
+		case PaymentPledged:
+			payment = PaymentPledged
+		}
+	}
+	return payment
+}
+
+// collectedAmt returns how much of the Patron's pledge has been collected.
+func (patron *Patron) collectedAmt() int {
+	var collected int
+	for _, pledge := range patron.pledges {
+		if pledge.Payment == "" || pledge.Payment == PaymentCollected {
+			collected += pledge.Amount
+		}
+	}
+	return collected
+}
+
+// collectedCells returns how many of the Patron's whole cells are covered by
+// money that has been collected, including the matched funds that go with it.
+func (patron *Patron) collectedCells() int {
+	collected := float64(patron.collectedAmt()) + patron.matchedCollected()
+	return int(collected/float64(patron.cellPrice) + 1e-9)
+}
+
+// setPending marks the cell as funded by money that hasn't been collected.
+// The payment status is kept apart from the status, since the status is
+// replaced if the cell ends up waitlisted or honorary. Only adopted cells are
+// shown as pending; community cells keep their own status.
+func (cell *Cell) setPending() {
+	if cell.status == CellAdopted {
+		cell.status = CellPending
+	}
+	cell.payment = PaymentPledged
+}
+
+// markPending sets a cell to pending if any of its adoptees still owe money.
+func markPending(cell *Cell) {
+	for _, adoptee := range cell.adoptees {
+		if adoptee.collectedAmt() < adoptee.pledgeAmt {
+			cell.setPending()
+			return
+		}
+	}
+}
+
+// PaymentRecord is a line of a reconciliation file, giving the payment status
+// of a pledge that was checked against the payment processor.
+type PaymentRecord struct {
+	Name       string
+	PledgeTime time.Time
+	Amount     int
+	Payment    string
+}
+
+// Reconciliation is a list of PaymentRecords loaded from a CSV file.
+type Reconciliation struct {
+	records []PaymentRecord
+}
+
+// LoadReconciliation reads a CSV reconciliation file with "name",
+// "pledge_time" and "payment" columns, and an optional "amount" column. A
+// missing file is treated as empty.
+func LoadReconciliation(fileName string) (*Reconciliation, error) {
+	recon := &Reconciliation{}
+
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return recon, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return recon, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "pledge_time", "payment"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("%s: missing %q column", fileName, required)
+		}
+	}
+	amountIdx, hasAmount := cols["amount"]
+
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+
+		record := PaymentRecord{
+			Name:    strings.TrimSpace(values[cols["name"]]),
+			Payment: parsePayment(values[cols["payment"]]),
+		}
+		if record.Payment == "" {
+			return nil, fmt.Errorf("%s:%d: unknown payment status %q", fileName, line, values[cols["payment"]])
+		}
+		record.PledgeTime, err = time.Parse(timeLayout, strings.TrimSpace(values[cols["pledge_time"]])+" "+timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		if hasAmount && strings.TrimSpace(values[amountIdx]) != "" {
+			if record.Amount, err = strconv.Atoi(strings.TrimSpace(values[amountIdx])); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+			}
+		}
+		recon.records = append(recon.records, record)
+	}
+
+	return recon, nil
+}
+
+// Apply sets the payment status of every Patron that matches a record. The
+// reconciliation file takes priority over the export.
+func (recon *Reconciliation) Apply(patrons []*Patron) {
+	for _, record := range recon.records {
+		for _, patron := range patrons {
+			if !strings.EqualFold(record.Name, patron.sourceName) || !record.PledgeTime.Equal(patron.pledgeTime) {
+				continue
+			}
+			if record.Amount != 0 && record.Amount != patron.pledgeAmt {
+				continue
+			}
+			patron.SetPayment(record.Payment)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestUncollectedCellsArePending(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reconFile := path.Join(dir, "payments.csv")
+	ioutil.WriteFile(reconFile, []byte(
+		"name,pledge_time,amount,payment\n"+
+			"Jane Smith,2019-03-31 08:21:16,100,pledged\n"+
+			"John Doe,2019-04-01 10:00:00,,paid\n"), 0644)
+
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 50),
+	}
+	recon, err := data.LoadReconciliation(reconFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recon.Apply(patrons)
+
+	if patrons[0].Payment() != data.PaymentPledged || patrons[1].Payment() != data.PaymentCollected {
+		t.Error("For", "Apply()", "expected", "pledged collected", "got", patrons[0].Payment(), patrons[1].Payment())
+	}
+
+	out := data.NewCellList(data.NewPatronList(patrons), nil).String()
+	if pending := strings.Count(out, `"status": "pending"`); pending != 2 {
+		t.Error("For", "pending cells", "expected", 2, "got", pending)
+	}
+	if adopted := strings.Count(out, `"status": "adopted"`); adopted != 1 {
+		t.Error("For", "adopted cells", "expected", 1, "got", adopted)
+	}
+}
+
+func TestOverflowKeepsPaymentStatus(t *testing.T) {
+	jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100)
+	jane.SetPayment(data.PaymentPledged)
+	list := data.NewCellList(data.NewPatronList([]*data.Patron{jane}), &data.CellOptions{
+		Capacity: 1,
+		Overflow: data.OverflowWaitlist,
+	})
+
+	var published struct {
+		Overflow []struct {
+			Status  string `json:"status"`
+			Payment string `json:"payment"`
+		} `json:"overflow"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	if len(published.Overflow) != 1 {
+		t.Fatal("For", "overflow", "expected", 1, "got", len(published.Overflow))
+	}
+	cell := published.Overflow[0]
+	if cell.Status != data.CellWaitlisted || cell.Payment != data.PaymentPledged {
+		t.Error("For", "waitlisted cell", "expected", "waitlisted / pledged", "got", cell.Status, cell.Payment)
+	}
+}
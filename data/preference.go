@@ -0,0 +1,301 @@
+package data
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PlacementHonored means a donor's cells were placed where they asked.
+	PlacementHonored string = "honored"
+	// PlacementFallback means the request couldn't be met and the cells were
+	// placed in the fallback region instead.
+	PlacementFallback string = "fallback"
+	// PlacementUnavailable means neither the request nor the fallback region
+	// had room, so the cells were placed normally.
+	PlacementUnavailable string = "unavailable"
+)
+
+// placementHeaders lists the column headings that may hold a placement request.
+var placementHeaders = []string{"placement", "placement request", "cell preference", "preferred cell"}
+
+// nearPrefixes are the ways a donor may ask to be placed beside someone else.
+var nearPrefixes = []string{"next to ", "near ", "beside "}
+
+// preference is a parsed placement request. Exactly one of cellID, region or
+// near is set.
+type preference struct {
+	cellID int
+	region string
+	near   string
+}
+
+// parsePreference reads a placement request such as "cell 112", "front" or
+// "next to Jane Smith".
+func parsePreference(text string) preference {
+	lower := strings.ToLower(strings.TrimSpace(text))
+
+	for _, prefix := range nearPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return preference{near: strings.TrimSpace(lower[len(prefix):])}
+		}
+	}
+
+	number := strings.TrimLeft(strings.TrimPrefix(lower, "cell"), " #")
+	if id, err := strconv.Atoi(number); err == nil {
+		return preference{cellID: id}
+	}
+
+	return preference{region: lower}
+}
+
+// SetPlacement records the Patron's placement request.
+func (patron *Patron) SetPlacement(text string) {
+	patron.placement = strings.TrimSpace(text)
+}
+
+// Placement returns the Patron's placement request, if they made one.
+func (patron *Patron) Placement() string {
+	return patron.placement
+}
+
+// requester returns the Patron behind an owner's placement request, or nil if
+// the owner didn't make one. Shared cells never carry a request.
+func requester(cells []*Cell) *Patron {
+	if len(cells) == 0 || len(cells[0].adoptees) != 1 {
+		return nil
+	}
+	if patron := cells[0].adoptees[0]; patron.placement != "" {
+		return patron
+	}
+	return nil
+}
+
+// positionsOf returns the positions already given to the donor with the given
+// lowercased name.
+func positionsOf(name string, cells []*Cell) []*Position {
+	var positions []*Position
+	for _, cell := range cells {
+		if cell.pos != nil && len(cell.adoptees) == 1 && strings.ToLower(cell.adoptees[0].sourceName) == name {
+			positions = append(positions, cell.pos)
+		}
+	}
+	return positions
+}
+
+// distance is the number of steps between two positions, counting diagonals
+// as one step.
+func distance(a, b *Position) int {
+	return maxInt(absInt(a.Row-b.Row), absInt(a.Col-b.Col))
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// nearest returns the positions sorted by how close they are to any of the
+// targets.
+func nearest(positions, targets []*Position) []*Position {
+	closest := make(map[int]int)
+	for _, pos := range positions {
+		best := -1
+		for _, target := range targets {
+			if d := distance(pos, target); best < 0 || d < best {
+				best = d
+			}
+		}
+		closest[pos.ID] = best
+	}
+
+	sorted := make([]*Position, len(positions))
+	copy(sorted, positions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return closest[sorted[i].ID] < closest[sorted[j].ID]
+	})
+	return sorted
+}
+
+// candidates returns the positions that would satisfy pref, in the order they
+// should be tried, along with whether they must be placed as a block. Nil is
+// returned if the request can't be met at all.
+func (alloc *allocator) candidates(pref preference, cells []*Cell) ([]*Position, bool) {
+	layout := alloc.opts.Layout
+	switch {
+	case pref.cellID > 0:
+		target, ok := layout.Lookup(pref.cellID)
+		if !ok || !alloc.free[target.ID] {
+			return nil, false
+		}
+		return nearest(alloc.order, []*Position{target}), false
+	case pref.near != "":
+		targets := positionsOf(pref.near, cells)
+		if len(targets) == 0 {
+			return nil, false
+		}
+		var open []*Position
+		for _, pos := range alloc.order {
+			if alloc.free[pos.ID] {
+				open = append(open, pos)
+			}
+		}
+		sorted := nearest(open, targets)
+		if len(sorted) == 0 {
+			return nil, false
+		}
+		// The closest free position has to actually touch the other donor.
+		for _, target := range targets {
+			if distance(sorted[0], target) == 1 {
+				return sorted, false
+			}
+		}
+		return nil, false
+	default:
+		return alloc.region(pref.region), true
+	}
+}
+
+// region returns the positions of the named region in the fill order.
+func (alloc *allocator) region(name string) []*Position {
+	positions, ok := alloc.opts.Layout.Region(name)
+	if !ok {
+		return nil
+	}
+	inRegion := make(map[int]bool)
+	for _, pos := range positions {
+		inRegion[pos.ID] = true
+	}
+
+	var ordered []*Position
+	for _, pos := range alloc.order {
+		if inRegion[pos.ID] {
+			ordered = append(ordered, pos)
+		}
+	}
+	return ordered
+}
+
+// allocateWithin takes n free positions from candidates, as a block when
+// compact is set and the block mode allows it. Nil is returned unless all n
+// cells fit.
+func (alloc *allocator) allocateWithin(n int, candidates []*Position, compact bool) []*Position {
+	if n > alloc.remaining {
+		return nil
+	}
+
+	allowed := make(map[int]bool)
+	var open []*Position
+	for _, pos := range candidates {
+		if alloc.free[pos.ID] {
+			allowed[pos.ID] = true
+			open = append(open, pos)
+		}
+	}
+	if len(open) < n {
+		return nil
+	}
+
+	if compact && n > 1 && alloc.opts.Block != BlockScattered && alloc.opts.Block != "" {
+		if block := alloc.findBlock(n, alloc.opts.Block == BlockModule, allowed); block != nil {
+			for _, pos := range block {
+				alloc.take(pos)
+			}
+			return block
+		}
+	}
+
+	for _, pos := range open[:n] {
+		alloc.take(pos)
+	}
+	return open[:n]
+}
+
+// placePreferred tries to place waiting cells according to the owner's
+// request, then in the fallback region. True is returned if the cells were
+// placed.
+func (alloc *allocator) placePreferred(waiting []*Cell, patron *Patron, cells []*Cell, assigned map[*Cell]bool) bool {
+	pref := parsePreference(patron.placement)
+
+	positions := alloc.tryPreference(len(waiting), pref, cells)
+	if positions == nil && alloc.opts.FallbackRegion != "" {
+		positions = alloc.allocateWithin(len(waiting), alloc.region(alloc.opts.FallbackRegion), true)
+	}
+	if positions == nil {
+		return false
+	}
+
+	for i, pos := range positions {
+		waiting[i].pos = pos
+		assigned[waiting[i]] = true
+	}
+	return true
+}
+
+// tryPreference allocates n positions that satisfy pref, or returns nil.
+func (alloc *allocator) tryPreference(n int, pref preference, cells []*Cell) []*Position {
+	candidates, compact := alloc.candidates(pref, cells)
+	if candidates == nil {
+		return nil
+	}
+	return alloc.allocateWithin(n, candidates, compact)
+}
+
+// placementResult works out whether the positions given to an owner satisfy
+// their request. It's checked after the fact so that the result stays correct
+// on later runs, when the owner simply keeps their earlier positions.
+func placementResult(owned []*Cell, cells []*Cell, opts *CellOptions) string {
+	pref := parsePreference(owned[0].adoptees[0].placement)
+	var positions []*Position
+	for _, cell := range owned {
+		if cell.pos != nil {
+			positions = append(positions, cell.pos)
+		}
+	}
+	if len(positions) == 0 {
+		return PlacementUnavailable
+	}
+
+	within := func(name string) bool {
+		area, ok := opts.Layout.Region(name)
+		if !ok {
+			return false
+		}
+		inArea := make(map[int]bool)
+		for _, pos := range area {
+			inArea[pos.ID] = true
+		}
+		for _, pos := range positions {
+			if !inArea[pos.ID] {
+				return false
+			}
+		}
+		return true
+	}
+
+	honored := false
+	switch {
+	case pref.cellID > 0:
+		for _, pos := range positions {
+			honored = honored || pos.ID == pref.cellID
+		}
+	case pref.near != "":
+		for _, target := range positionsOf(pref.near, cells) {
+			for _, pos := range positions {
+				honored = honored || distance(pos, target) == 1
+			}
+		}
+	default:
+		honored = within(pref.region)
+	}
+
+	switch {
+	case honored:
+		return PlacementHonored
+	case opts.FallbackRegion != "" && within(opts.FallbackRegion):
+		return PlacementFallback
+	}
+	return PlacementUnavailable
+}
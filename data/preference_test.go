@@ -0,0 +1,100 @@
+package data_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// preferenceLayout is testLayout with a "front" region along the first row.
+const preferenceLayout string = `{
+	"modules": [
+		{"name": "A", "string": "S1", "row": 0, "col": 0, "rows": 3, "cols": 4},
+		{"name": "B", "string": "S1", "row": 0, "col": 4, "rows": 3, "cols": 4}
+	],
+	"regions": {"front": {"row": 0, "col": 0, "rows": 1, "cols": 8}}
+}`
+
+func TestPlacementPreferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(preferenceLayout), 0644)
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		placement string
+		fallback  string
+		amount    int
+		result    string
+		ids       []int
+	}{
+		{"cell 7", "", 50, data.PlacementHonored, []int{7}},
+		{"#20", "", 50, data.PlacementHonored, []int{20}},
+		{"B", "", 100, data.PlacementHonored, []int{13, 14}},
+		{"Front", "", 100, data.PlacementHonored, []int{3, 4}},
+		{"next to Jane Smith", "", 50, data.PlacementHonored, []int{5}},
+		{"next to Sam Jones", "B", 50, data.PlacementFallback, []int{13}},
+		{"cell 99", "B", 50, data.PlacementFallback, []int{13}},
+		{"cell 1", "B", 50, data.PlacementFallback, []int{13}},
+		{"the roof", "", 50, data.PlacementUnavailable, []int{3}},
+		{"the roof", "nowhere", 50, data.PlacementUnavailable, []int{3}},
+	}
+	for _, test := range tests {
+		opts := &data.CellOptions{
+			Layout:         layout,
+			Fill:           data.FillRowMajor,
+			Block:          data.BlockModule,
+			FallbackRegion: test.fallback,
+			Assignments:    make(map[string][]int),
+		}
+
+		// Jane and Ann adopted the first two cells on an earlier run.
+		jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+		ann := data.NewPatron(2, "2019-04-01 10:00:00", false, "Ann", "Lee", 50)
+		data.NewCellList(data.NewPatronList([]*data.Patron{ann, jane}), opts)
+
+		jane = data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+		ann = data.NewPatron(2, "2019-04-01 10:00:00", false, "Ann", "Lee", 50)
+		john := data.NewPatron(3, "2019-04-02 10:00:00", false, "John", "Doe", test.amount)
+		john.SetPlacement(test.placement)
+		list := data.NewCellList(data.NewPatronList([]*data.Patron{john, ann, jane}), opts)
+
+		var ids []int
+		var results []string
+		for _, cell := range publishedCells(t, list) {
+			named := cell["named_adoptees"].([]interface{})[0].(map[string]interface{})
+			if named["name"] != "John Doe" {
+				continue
+			}
+			ids = append(ids, int(cell["id"].(float64)))
+			if result, ok := cell["placement"].(string); ok {
+				results = append(results, result)
+			}
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Error("For", test.placement, test.fallback, "expected", test.ids, "got", ids)
+		}
+		for _, result := range results {
+			if result != test.result {
+				t.Error("For", test.placement, test.fallback, "expected", test.result, "got", result)
+			}
+		}
+		if len(results) != len(test.ids) {
+			t.Error("For", test.placement, "expected", len(test.ids), "placement results", "got", len(results))
+		}
+		if report := list.PlacementReport(); report[test.result] != 1 {
+			t.Error("For", test.placement, "expected", test.result, "in the report", "got", report)
+		}
+	}
+}
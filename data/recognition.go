@@ -0,0 +1,243 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBoardSize is how many donors each leaderboard lists unless the
+// recognition file says otherwise.
+const DefaultBoardSize int = 10
+
+// Tier is a recognition level for donors who've given at least Min dollars.
+// Reward names the physical reward that goes with it, if any.
+type Tier struct {
+	Name   string `json:"name"`
+	Min    int    `json:"min"`
+	Reward string `json:"reward,omitempty"`
+}
+
+// Recognition configures the leaderboards and tiers published with the
+// campaign data. Nothing is published without one, since the leaderboards
+// name donors.
+type Recognition struct {
+	Top    int    `json:"top"`
+	Recent int    `json:"recent"`
+	First  int    `json:"first"`
+	Tiers  []Tier `json:"tiers"`
+}
+
+// LoadRecognition reads the recognition file at fileName. Leaderboards left
+// out of the file get the default size, and tiers are sorted from the highest
+// down.
+func LoadRecognition(fileName string) (*Recognition, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	recognition := &Recognition{Top: -1, Recent: -1, First: -1}
+	if err := json.Unmarshal(content, recognition); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	for _, size := range []*int{&recognition.Top, &recognition.Recent, &recognition.First} {
+		if *size < 0 {
+			*size = DefaultBoardSize
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, tier := range recognition.Tiers {
+		if tier.Name == "" || tier.Min <= 0 {
+			return nil, fmt.Errorf("%s: tier %d needs a name and a minimum above 0", fileName, i)
+		}
+		if names[strings.ToLower(tier.Name)] {
+			return nil, fmt.Errorf("%s: tier %q is defined twice", fileName, tier.Name)
+		}
+		names[strings.ToLower(tier.Name)] = true
+	}
+	sort.SliceStable(recognition.Tiers, func(i, j int) bool {
+		return recognition.Tiers[i].Min > recognition.Tiers[j].Min
+	})
+
+	return recognition, nil
+}
+
+// TierFor returns the highest tier that amount qualifies for.
+func (recognition *Recognition) TierFor(amount int) (Tier, bool) {
+	for _, tier := range recognition.Tiers {
+		if amount >= tier.Min {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// HasRewards reports whether any tier comes with a physical reward.
+func (recognition *Recognition) HasRewards() bool {
+	for _, tier := range recognition.Tiers {
+		if tier.Reward != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRecognition attaches the leaderboards and tiers to the CellList so that
+// they're published.
+func (list *CellList) SetRecognition(recognition *Recognition) {
+	list.recognition = recognition
+}
+
+// TierMembers returns the donors in each tier, keyed by tier name. Each donor
+// is only in the highest tier they qualify for.
+func (list *CellList) TierMembers(recognition *Recognition) map[string][]*Patron {
+	members := make(map[string][]*Patron)
+	for _, patron := range list.recognized() {
+		if tier, ok := recognition.TierFor(patron.pledgeAmt); ok {
+			members[tier.Name] = append(members[tier.Name], patron)
+		}
+	}
+	return members
+}
+
+// recognized returns the donors who can be recognized: anyone whose pledge
+// to this campaign still stands. Refunded and cancelled pledges drop off every
+// list, and credit carried in from an earlier campaign was recognized there.
+func (list *CellList) recognized() []*Patron {
+	var patrons []*Patron
+	for _, patron := range list.patrons.patrons {
+		if patron.status == PledgeActive && !patron.carried && patron.pledgeAmt > 0 {
+			patrons = append(patrons, patron)
+		}
+	}
+	return patrons
+}
+
+// boardEntry is one donor on a published leaderboard or tier. The name is the
+// published one, so anonymous donors stay anonymous.
+type boardEntry struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Amount int       `json:"amount"`
+	Cells  float32   `json:"cells"`
+	Time   time.Time `json:"time"`
+	Tier   string    `json:"tier,omitempty"`
+}
+
+// boardEntries turns patrons into published entries, up to size of them.
+func (list *CellList) boardEntries(patrons []*Patron, size int, at func(*Patron) time.Time) []boardEntry {
+	entries := []boardEntry{}
+	for _, patron := range patrons {
+		if len(entries) == size {
+			break
+		}
+		entry := boardEntry{
+			ID:     patron.id,
+			Name:   patron.Name(),
+			Amount: patron.pledgeAmt,
+			Cells:  patron.cellAmt,
+			Time:   at(patron),
+		}
+		if tier, ok := list.recognition.TierFor(patron.pledgeAmt); ok {
+			entry.Tier = tier.Name
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// lastPledge returns the time of the Patron's most recent pledge.
+func lastPledge(patron *Patron) time.Time {
+	last := patron.pledgeTime
+	for _, pledge := range patron.pledges {
+		if pledge.Time.After(last) {
+			last = pledge.Time
+		}
+	}
+	return last
+}
+
+// firstPledge returns the time of the Patron's first pledge.
+func firstPledge(patron *Patron) time.Time {
+	return patron.pledgeTime
+}
+
+// leaderboardsJSON formats the top donors, most recent adopters and first
+// donors for the published data.
+func (list *CellList) leaderboardsJSON() ([]byte, error) {
+	recognition := list.recognition
+	patrons := list.recognized()
+
+	// Matching sponsors are listed by amount, but their pledge time is only
+	// that of the first matched pledge, so they're left off the lists that
+	// go by time.
+	var donors []*Patron
+	for _, patron := range patrons {
+		if len(patron.sponsorFor) == 0 {
+			donors = append(donors, patron)
+		}
+	}
+
+	top := append([]*Patron{}, patrons...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].pledgeAmt > top[j].pledgeAmt
+	})
+
+	adopters := make(map[int]bool)
+	for _, cell := range list.cells {
+		if cell.status == CellAdopted {
+			for _, id := range cell.adopteeIDs {
+				adopters[id] = true
+			}
+		}
+	}
+	var recent []*Patron
+	for _, patron := range donors {
+		if adopters[patron.id] {
+			recent = append(recent, patron)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		return lastPledge(recent[i]).After(lastPledge(recent[j]))
+	})
+
+	first := append([]*Patron{}, donors...)
+	sort.SliceStable(first, func(i, j int) bool {
+		return first[i].pledgeTime.Before(first[j].pledgeTime)
+	})
+
+	return json.Marshal(map[string][]boardEntry{
+		"top_donors":      list.boardEntries(top, recognition.Top, firstPledge),
+		"recent_adopters": list.boardEntries(recent, recognition.Recent, lastPledge),
+		"first_donors":    list.boardEntries(first, recognition.First, firstPledge),
+	})
+}
+
+// tiersJSON formats each tier with its members for the published data.
+func (list *CellList) tiersJSON() ([]byte, error) {
+	type tierJSON struct {
+		Tier
+		Count   int          `json:"count"`
+		Members []boardEntry `json:"members"`
+	}
+
+	members := list.TierMembers(list.recognition)
+	tiers := []tierJSON{}
+	for _, tier := range list.recognition.Tiers {
+		patrons := members[tier.Name]
+		sort.SliceStable(patrons, func(i, j int) bool {
+			return patrons[i].pledgeAmt > patrons[j].pledgeAmt
+		})
+		tiers = append(tiers, tierJSON{
+			Tier:    tier,
+			Count:   len(patrons),
+			Members: list.boardEntries(patrons, -1, firstPledge),
+		})
+	}
+	return json.Marshal(tiers)
+}
@@ -0,0 +1,94 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestRecognition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recognition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recognitionFile := path.Join(dir, "recognition.json")
+	ioutil.WriteFile(recognitionFile, []byte(`{
+		"top": 2,
+		"tiers": [
+			{"name": "Bronze Sponsor", "min": 50, "reward": "Sticker"},
+			{"name": "Silver Sponsor", "min": 150, "reward": "T-shirt"}
+		]
+	}`), 0644)
+	recognition, err := data.LoadRecognition(recognitionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recognition.Recent != data.DefaultBoardSize {
+		t.Error("For", "recent", "expected", data.DefaultBoardSize, "got", recognition.Recent)
+	}
+
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 100),
+		data.NewPatron(2, "2019-04-01 10:00:00", true, "John", "Doe", 200),
+		data.NewPatron(3, "2019-04-02 10:00:00", false, "Ann", "Lee", 25),
+	}
+	// Credit carried in from an earlier campaign isn't recognized again.
+	carried := data.CarriedPatrons([]data.Carryover{{Name: "Bob Ray", PledgeTime: "2018-05-01 10:00:00", Amount: 500}}, 4)
+	list := data.NewCellList(data.NewPatronList(append(patrons, carried...)), nil)
+
+	// The leaderboards name donors, so nothing is published until the
+	// campaign sets them up.
+	if out := list.String(); strings.Contains(out, "leaderboards") || strings.Contains(out, "tiers") {
+		t.Error("For", "no recognition", "expected", "no leaderboards or tiers", "got", out)
+	}
+	list.SetRecognition(recognition)
+
+	var published struct {
+		Leaderboards map[string][]struct {
+			Name   string `json:"name"`
+			Amount int    `json:"amount"`
+			Tier   string `json:"tier"`
+		} `json:"leaderboards"`
+		Tiers []struct {
+			Name   string `json:"name"`
+			Reward string `json:"reward"`
+			Count  int    `json:"count"`
+		} `json:"tiers"`
+	}
+	content, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &published); err != nil {
+		t.Fatal(err)
+	}
+
+	top := published.Leaderboards["top_donors"]
+	if len(top) != 2 {
+		t.Fatal("For", "top donors", "expected", 2, "got", len(top))
+	}
+	if top[0].Name != "Anonymous Donor" || top[0].Amount != 200 || top[0].Tier != "Silver Sponsor" {
+		t.Error("For", "top donor", "expected", "Anonymous Donor 200 Silver Sponsor", "got", top[0])
+	}
+	if first := published.Leaderboards["first_donors"]; len(first) != 3 || first[0].Name != "Jane Smith" {
+		t.Error("For", "first donors", "expected", "Jane Smith first", "got", first)
+	}
+	// Ann's $25 doesn't add up to a cell, so she isn't an adopter.
+	if recent := published.Leaderboards["recent_adopters"]; len(recent) != 2 || recent[0].Amount != 200 {
+		t.Error("For", "recent adopters", "expected", "2, latest first", "got", recent)
+	}
+
+	if len(published.Tiers) != 2 || published.Tiers[0].Name != "Silver Sponsor" {
+		t.Fatal("For", "tiers", "expected", "Silver Sponsor first", "got", published.Tiers)
+	}
+	if published.Tiers[0].Count != 1 || published.Tiers[1].Count != 1 || published.Tiers[1].Reward != "Sticker" {
+		t.Error("For", "tier members", "expected", "one each", "got", published.Tiers)
+	}
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/logging"
+)
+
+const (
+	// CellInProgress is the status of a cell a recurring donor is still
+	// paying toward.
+	CellInProgress string = "in_progress"
+
+	// defaultInterval is the assumed time between installments when a
+	// subscription only has one so far.
+	defaultInterval time.Duration = 30 * 24 * time.Hour
+)
+
+// subscriptionHeaders lists the column headings that may hold the id of the
+// recurring donation a pledge belongs to.
+var subscriptionHeaders = []string{"subscription", "subscription id", "recurring id", "recurring donation id"}
+
+// SetSubscription records the id of the recurring donation the Patron's
+// pledge belongs to.
+func (patron *Patron) SetSubscription(id string) {
+	patron.subscription = strings.TrimSpace(id)
+}
+
+// Subscription returns the id of the recurring donation the Patron's pledges
+// belong to, or "" for a one-off pledge.
+func (patron *Patron) Subscription() string {
+	return patron.subscription
+}
+
+// GroupInstallments combines the installments of each recurring donation into
+// a single Patron, keyed by donor and subscription. The installments are kept
+// in the Patron's pledge history. Unlike MergeRepeatDonors this always runs,
+// since an installment on its own is never a complete pledge.
+func GroupInstallments(patrons []*Patron) []*Patron {
+	first := make(map[string]*Patron)
+	var grouped []*Patron
+	for _, patron := range patrons {
+		if patron.subscription == "" {
+			grouped = append(grouped, patron)
+			continue
+		}
+
+		key := strings.ToLower(patron.sourceName) + "\x00" + strings.ToLower(patron.subscription)
+		if owner, ok := first[key]; ok {
+			owner.absorb(patron)
+			continue
+		}
+		first[key] = patron
+		grouped = append(grouped, patron)
+	}
+
+	if len(grouped) < len(patrons) {
+		for i, patron := range grouped {
+			patron.id = i + 1
+		}
+	}
+	return grouped
+}
+
+// ProjectedCompletion estimates when a recurring donor's installments will
+// add up to their next whole cell, based on their average installment and the
+// average time between installments. Matched funds applied to the donor count
+// toward both the cell and the installments. It returns false if the Patron
+// isn't a recurring donor or their cells are already complete.
+func (patron *Patron) ProjectedCompletion() (time.Time, bool) {
+	if patron.subscription == "" || len(patron.pledges) == 0 || patron.cellPrice <= 0 {
+		return time.Time{}, false
+	}
+	raised := float64(patron.pledgeAmt)
+	for _, credit := range patron.matches {
+		if credit.applied {
+			raised += credit.amount
+		}
+	}
+	price := float64(patron.cellPrice)
+	remaining := math.Ceil(raised/price)*price - raised
+	if remaining <= 0 {
+		return time.Time{}, false
+	}
+
+	pledges := make([]Pledge, len(patron.pledges))
+	copy(pledges, patron.pledges)
+	sort.Slice(pledges, func(i, j int) bool {
+		return pledges[i].Time.Before(pledges[j].Time)
+	})
+	last := pledges[len(pledges)-1].Time
+
+	interval := defaultInterval
+	if len(pledges) > 1 {
+		interval = last.Sub(pledges[0].Time) / time.Duration(len(pledges)-1)
+	}
+	average := raised / float64(len(pledges))
+	if average <= 0 {
+		return time.Time{}, false
+	}
+
+	installments := math.Ceil(remaining / average)
+	return last.Add(time.Duration(installments) * interval), true
+}
+
+// newInProgressCell creates the cell a recurring donor is still paying toward.
+// The fill is how much of it their installments cover so far.
+func newInProgressCell(id int, patron *Patron, fill float32, logger *logging.Logger) *Cell {
+	cell := newCell(id, []*Patron{patron}, logger)
+	cell.status = CellInProgress
+	cell.fill = fill
+	cell.completion, _ = patron.ProjectedCompletion()
+	return cell
+}
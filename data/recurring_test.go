@@ -0,0 +1,60 @@
+package data_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestGroupInstallments(t *testing.T) {
+	var patrons []*data.Patron
+	for i, month := range []string{"01", "02", "03"} {
+		patron := data.NewPatron(i+1, "2019-"+month+"-01 12:00:00", false, "Jane", "Smith", 20)
+		patron.SetSubscription("sub_1")
+		patrons = append(patrons, patron)
+	}
+	patrons = append(patrons, data.NewPatron(4, "2019-03-02 12:00:00", false, "John", "Doe", 50))
+
+	grouped := data.GroupInstallments(patrons)
+	if len(grouped) != 2 {
+		t.Fatal("For", "GroupInstallments()", "expected", 2, "got", len(grouped))
+	}
+	jane := grouped[0]
+	if jane.PledgeAmt() != 60 || len(jane.Pledges()) != 3 {
+		t.Error("For", "installments", "expected", "60 / 3 pledges", "got", jane.PledgeAmt(), len(jane.Pledges()))
+	}
+
+	// $40 is still owed at $20 a month, so two more installments.
+	completion, ok := jane.ProjectedCompletion()
+	last := jane.Pledges()[2].Time
+	if !ok || completion.Sub(last) < 55*24*time.Hour || completion.Sub(last) > 62*24*time.Hour {
+		t.Error("For", "ProjectedCompletion()", "expected", "about two months after", last, "got", completion)
+	}
+
+	list := data.NewCellList(data.NewPatronList(grouped), nil)
+	out := list.String()
+	if strings.Count(out, `"status": "in_progress"`) != 1 || !strings.Contains(out, `"fill_percent": 20.0`) {
+		t.Error("For", "in progress cell", "expected", "one cell at 20%", "got", out)
+	}
+	if totals := list.Totals(); totals.InProgress != 1 || totals.Cells != 2 {
+		t.Error("For", "Totals()", "expected", "1 in progress / 2 cells", "got", totals.InProgress, totals.Cells)
+	}
+}
+
+func TestProjectedCompletionWithMatches(t *testing.T) {
+	var patrons []*data.Patron
+	for i, month := range []string{"01", "02", "03"} {
+		patron := data.NewPatron(i+1, "2019-"+month+"-01 12:00:00", false, "Jane", "Smith", 20)
+		patron.SetSubscription("sub_1")
+		patrons = append(patrons, patron)
+	}
+	gifts := []*data.MatchingGift{{Sponsor: "Acme Solar", Multiplier: 0.5, Apply: data.MatchDonor}}
+
+	// $60 pledged and $30 matched leaves $10 owed, one more $30 installment.
+	out := data.NewCellList(data.NewPatronList(data.GroupInstallments(patrons)), &data.CellOptions{MatchingGifts: gifts}).String()
+	if !strings.Contains(out, `"fill_percent": 80.0`) || !strings.Contains(out, `"projected_completion": "2019-03-31"`) {
+		t.Error("For", "matched installments", "expected", "80% done by 2019-03-31", "got", out)
+	}
+}
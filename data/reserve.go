@@ -0,0 +1,117 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Reservation holds a set of positions back from automatic assignment, for
+// example for a sponsor who has been promised cells before paying online.
+// Once a pledge from the named patron arrives, their cells are placed in the
+// reserved positions.
+type Reservation struct {
+	Label   string  `json:"label"`
+	Cells   []int   `json:"cells,omitempty"`
+	Region  *region `json:"region,omitempty"`
+	Module  string  `json:"module,omitempty"`
+	Patron  string  `json:"patron,omitempty"`
+	Comment string  `json:"comment,omitempty"`
+
+	positions []*Position
+}
+
+// LoadReservations reads the reservations file at fileName and resolves each
+// one against the layout. It is an error for a reservation to list a cell that
+// isn't on the array or is off-limits, or to take a cell that is already
+// reserved. Off-limits cells inside a reserved region or module are skipped.
+func LoadReservations(fileName string, layout *Layout) ([]*Reservation, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservations []*Reservation
+	if err := json.Unmarshal(content, &reservations); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	taken := make(map[int]string)
+	for i, reservation := range reservations {
+		if reservation.Label == "" {
+			reservation.Label = "Reserved"
+		}
+		if err := reservation.resolve(layout); err != nil {
+			return nil, fmt.Errorf("%s: reservation %d (%s): %v", fileName, i, reservation.Label, err)
+		}
+		for _, pos := range reservation.positions {
+			if other, dup := taken[pos.ID]; dup {
+				return nil, fmt.Errorf("%s: cell %d is reserved by both %q and %q", fileName, pos.ID, other, reservation.Label)
+			}
+			taken[pos.ID] = reservation.Label
+		}
+	}
+
+	return reservations, nil
+}
+
+// resolve turns the cells, region and module of the Reservation into
+// positions on the layout. Regions and modules only take the cells in them
+// that can be adopted.
+func (reservation *Reservation) resolve(layout *Layout) error {
+	add := func(pos *Position) {
+		if !pos.OffLimits {
+			reservation.positions = append(reservation.positions, pos)
+		}
+	}
+
+	for _, id := range reservation.Cells {
+		pos, ok := layout.Lookup(id)
+		if !ok {
+			return fmt.Errorf("cell %d is not on the array", id)
+		}
+		if pos.OffLimits {
+			return fmt.Errorf("cell %d is off-limits", pos.ID)
+		}
+		add(pos)
+	}
+
+	if reservation.Region != nil {
+		for row := 0; row < reservation.Region.Rows; row++ {
+			for col := 0; col < reservation.Region.Cols; col++ {
+				pos, ok := layout.At(reservation.Region.Row+row, reservation.Region.Col+col)
+				if ok {
+					add(pos)
+				}
+			}
+		}
+	}
+
+	if reservation.Module != "" {
+		found := false
+		for _, pos := range layout.Positions() {
+			if pos.Module == reservation.Module {
+				add(pos)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("module %q is not on the array", reservation.Module)
+		}
+	}
+
+	if len(reservation.positions) == 0 {
+		return fmt.Errorf("no cells reserved")
+	}
+	return nil
+}
+
+// claims reports whether the cell belongs to the patron this Reservation is
+// being held for. Only cells with a single adoptee can claim a reservation.
+func (reservation *Reservation) claims(cell *Cell) bool {
+	if reservation.Patron == "" || len(cell.adoptees) != 1 {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(reservation.Patron), cell.adoptees[0].sourceName)
+}
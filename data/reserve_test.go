@@ -0,0 +1,135 @@
+package data_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// reserveLayout is testLayout with the second cell of module A off-limits.
+const reserveLayout string = `{
+	"modules": [
+		{"name": "A", "string": "S1", "row": 0, "col": 0, "rows": 3, "cols": 4},
+		{"name": "B", "string": "S1", "row": 0, "col": 4, "rows": 3, "cols": 4}
+	],
+	"off_limits": [{"row": 0, "col": 1}]
+}`
+
+// reservedIDs returns the ids of the cells published as reserved, and of the
+// cells that were placed in a reservation.
+func reservedIDs(t *testing.T, list *data.CellList) ([]int, map[int]string) {
+	var published struct {
+		Cells []struct {
+			ID    int    `json:"id"`
+			Label string `json:"label"`
+		} `json:"cells"`
+		Reserved []struct {
+			ID int `json:"id"`
+		} `json:"reserved"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, cell := range published.Reserved {
+		ids = append(ids, cell.ID)
+	}
+	labels := make(map[int]string)
+	for _, cell := range published.Cells {
+		if cell.Label != "" {
+			labels[cell.ID] = cell.Label
+		}
+	}
+	return ids, labels
+}
+
+func TestLoadReservations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(reserveLayout), 0644)
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		reservations string
+		reserved     []int
+	}{
+		{`[{"cells": [1, 3]}]`, []int{1, 3}},
+		{`[{"region": {"row": 0, "col": 0, "rows": 1, "cols": 3}}]`, []int{1, 3}},
+		{`[{"module": "B"}]`, []int{13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24}},
+		{`[{"module": "A"}]`, []int{1, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		{`[{"cells": [2]}]`, nil},
+		{`[{"cells": [99]}]`, nil},
+		{`[{"region": {"row": 0, "col": 1, "rows": 1, "cols": 1}}]`, nil},
+		{`[{"module": "Z"}]`, nil},
+		{`[{"cells": [1]}, {"module": "A"}]`, nil},
+	}
+	for _, test := range tests {
+		reserveFile := path.Join(dir, "reservations.json")
+		ioutil.WriteFile(reserveFile, []byte(test.reservations), 0644)
+
+		reservations, err := data.LoadReservations(reserveFile, layout)
+		if test.reserved == nil {
+			if err == nil {
+				t.Error("For", test.reservations, "expected", "an error", "got", nil)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("For", test.reservations, "expected", test.reserved, "got", err)
+			continue
+		}
+
+		list := data.NewCellList(data.NewPatronList(nil), &data.CellOptions{
+			Layout:       layout,
+			Reservations: reservations,
+		})
+		if reserved, _ := reservedIDs(t, list); !reflect.DeepEqual(reserved, test.reserved) {
+			t.Error("For", test.reservations, "expected", test.reserved, "got", reserved)
+		}
+	}
+}
+
+func TestReservationIsClaimed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(reserveLayout), 0644)
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserveFile := path.Join(dir, "reservations.json")
+	ioutil.WriteFile(reserveFile, []byte(`[
+		{"label": "Acme Solar", "region": {"row": 2, "col": 4, "rows": 1, "cols": 2}, "patron": "Jane Smith"}
+	]`), 0644)
+	reservations, err := data.LoadReservations(reserveFile, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jane := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+	list := data.NewCellList(data.NewPatronList([]*data.Patron{jane}), &data.CellOptions{
+		Layout:       layout,
+		Reservations: reservations,
+	})
+	reserved, labels := reservedIDs(t, list)
+	if !reflect.DeepEqual(reserved, []int{22}) || labels[21] != "Acme Solar" {
+		t.Error("For", "claimed reservation", "expected", "21 claimed / 22 reserved", "got", labels, reserved)
+	}
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Move records an adoption being transferred from one position on the array
+// to another, such as when a cracked cell is replaced or the array is rebuilt.
+type Move struct {
+	Owner  string    `json:"owner"`
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Transfer moves the adoption at position from to position to. It fails if
+// nobody holds from.
+func Transfer(assignments map[string][]int, from, to int, layout *Layout, reason string) (Move, error) {
+	if _, held := holders(assignments)[from]; !held {
+		return Move{}, fmt.Errorf("cell %d isn't adopted", from)
+	}
+	moves, err := Remap(assignments, map[int]int{from: to}, layout, reason)
+	if err != nil {
+		return Move{}, err
+	}
+	return moves[0], nil
+}
+
+// Remap moves every adoption in assignments according to mapping, which maps
+// old position ids to new ones. All moves happen at once, so cells can swap
+// places. Positions that nobody holds are skipped, and positions missing from
+// mapping stay where they are. Every target must be an open position on
+// layout, so adoptions can only be moved on an array with a layout.
+func Remap(assignments map[string][]int, mapping map[int]int, layout *Layout, reason string) ([]Move, error) {
+	if layout == nil {
+		return nil, fmt.Errorf("adoptions can only be moved on an array with a layout")
+	}
+	held := holders(assignments)
+
+	targets := make(map[int]int)
+	for from, to := range mapping {
+		if _, ok := held[from]; !ok {
+			continue
+		}
+		if other, dup := targets[to]; dup {
+			return nil, fmt.Errorf("cells %d and %d are both mapped to cell %d", other, from, to)
+		}
+		targets[to] = from
+
+		pos, ok := layout.Lookup(to)
+		if !ok {
+			return nil, fmt.Errorf("cell %d is not on the array", to)
+		}
+		if pos.OffLimits {
+			return nil, fmt.Errorf("cell %d is off-limits", to)
+		}
+	}
+
+	// A target that's already held has to be moving out of the way.
+	for to, from := range targets {
+		if _, ok := held[to]; !ok || to == from {
+			continue
+		}
+		if _, moving := mapping[to]; !moving {
+			return nil, fmt.Errorf("cell %d is already adopted by %s", to, held[to])
+		}
+	}
+
+	now := time.Now()
+	var moves []Move
+	for owner, ids := range assignments {
+		for i, id := range ids {
+			to, ok := mapping[id]
+			if !ok || to == id {
+				continue
+			}
+			ids[i] = to
+			moves = append(moves, Move{Owner: owner, From: id, To: to, Reason: reason, Time: now})
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].From < moves[j].From
+	})
+
+	return moves, nil
+}
+
+// LoadMapping reads a remapping file with one "old,new" pair of cell ids per
+// line. Blank lines, lines starting with '#' and a header line are ignored.
+func LoadMapping(fileName string) (map[int]int, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mapping := make(map[int]int)
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"old,new\"", fileName, lineNum)
+		}
+		from, errFrom := strconv.Atoi(strings.TrimSpace(fields[0]))
+		to, errTo := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if errFrom != nil || errTo != nil {
+			if lineNum == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("%s:%d: cell ids must be numbers", fileName, lineNum)
+		}
+		if _, dup := mapping[from]; dup {
+			return nil, fmt.Errorf("%s:%d: cell %d is mapped twice", fileName, lineNum, from)
+		}
+		mapping[from] = to
+	}
+
+	return mapping, scanner.Err()
+}
+
+// History returns the moves that brought owner's adoption to position pos,
+// oldest first.
+func History(moves []Move, owner string, pos int) []Move {
+	var history []Move
+	for i := len(moves) - 1; i >= 0; i-- {
+		if moves[i].Owner == owner && moves[i].To == pos {
+			history = append([]Move{moves[i]}, history...)
+			pos = moves[i].From
+		}
+	}
+	return history
+}
+
+// holders maps each held position id to its owner.
+func holders(assignments map[string][]int) map[int]string {
+	held := make(map[int]string)
+	for owner, ids := range assignments {
+		for _, id := range ids {
+			held[id] = owner
+		}
+	}
+	return held
+}
@@ -0,0 +1,72 @@
+package data_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+func TestRemapAndHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(`{
+		"modules": [{"name": "A", "string": "S1", "row": 0, "col": 0, "rows": 20, "cols": 20}],
+		"off_limits": [{"row": 19, "col": 18}]
+	}`), 0644)
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignments := map[string][]int{
+		"alice": {112, 113},
+		"bob":   {205},
+	}
+
+	// Swapping two adopted cells is fine since they move at the same time.
+	moves, err := data.Remap(assignments, map[int]int{112: 205, 205: 112, 300: 301}, layout, "rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 {
+		t.Error("For", "moves", "expected", 2, "got", len(moves))
+	}
+	if assignments["alice"][0] != 205 || assignments["bob"][0] != 112 {
+		t.Error("For", "swap", "expected", "[205 113] [112]", "got", assignments["alice"], assignments["bob"])
+	}
+
+	// Moving onto a cell someone else keeps is refused.
+	if _, err := data.Transfer(assignments, 113, 112, layout, "cracked"); err == nil {
+		t.Error("For", "113:112", "expected", "an error", "got", nil)
+	}
+	move, err := data.Transfer(assignments, 205, 400, layout, "cracked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves = append(moves, move)
+
+	// Targets have to be open cells on the layout, and there has to be one.
+	for _, to := range []int{399, 401} {
+		if _, err := data.Transfer(assignments, 113, to, layout, "cracked"); err == nil {
+			t.Error("For", to, "expected", "an error", "got", nil)
+		}
+	}
+	if _, err := data.Transfer(assignments, 113, 114, nil, "cracked"); err == nil {
+		t.Error("For", "no layout", "expected", "an error", "got", nil)
+	}
+
+	history := data.History(moves, "alice", 400)
+	if len(history) != 2 || history[0].From != 112 {
+		t.Error("For", "alice", "expected", "originally 112", "got", history)
+	}
+	if history := data.History(moves, "bob", 400); len(history) != 0 {
+		t.Error("For", "bob", "expected", "no history", "got", history)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+)
+
+// runForecast implements the "forecast" subcommand, which projects the
+// pledges in the campaign export forward to the goal milestones and the end
+// of the campaign. It takes the updater's flags, so the pledges are prepared
+// the same way as for the forecast published in stats.json.
+func runForecast(args []string) {
+	flags := flag.NewFlagSet("forecast", flag.ExitOnError)
+	windowPtr := flags.Int("window", stats.DefaultWindow, "The number of days covered by the moving average.")
+	settings := addReportFlags(flags)
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cellList, opts, err := settings.cellList()
+	if err != nil {
+		fail(err)
+	}
+
+	forecast := newForecast(cellList, opts.goal, opts.end, *windowPtr, opts.cellPrice)
+	if err := forecast.WriteReport(os.Stdout); err != nil {
+		fail(err)
+	}
+}
+
+// newForecast projects the pledges behind cellList forward. The goal and end
+// date are optional.
+func newForecast(cellList *data.CellList, goal *data.Goal, end time.Time, window, price int) *stats.Forecast {
+	forecast := stats.NewForecast(cellList.Pledges(), time.Now(), window, price)
+	if goal != nil {
+		forecast.Goal(goal, cellList.Raised(goal.Unit))
+	}
+	if !end.IsZero() {
+		forecast.CellsBy(end, cellList.Totals().Cells)
+	}
+	return forecast
+}
@@ -103,7 +103,7 @@ func (logger *Logger) Warnf(format string, v ...interface{}) {
 			panic(err)
 		}
 	} else {
-		log.Printf("WARN: %v\n", v...)
+		log.Printf("WARN: "+format, v...)
 	}
 }
 
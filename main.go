@@ -11,6 +11,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/iAmSomeone2/aacautoupdate/campaign"
 	"github.com/iAmSomeone2/aacautoupdate/data"
 	"github.com/iAmSomeone2/aacautoupdate/logging"
 	"github.com/iAmSomeone2/aacautoupdate/serve"
@@ -25,11 +26,45 @@ const (
 
 // Main sets up the main loop.
 func main() {
+	// Operator subcommands run once and exit instead of starting the loop.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "moderate":
+			runModerate(os.Args[2:])
+			return
+		case "refunds":
+			runRefunds(os.Args[2:])
+			return
+		case "cells":
+			runCells(os.Args[2:])
+			return
+		case "reconcile":
+			runReconcile(os.Args[2:])
+			return
+		case "forecast":
+			runForecast(os.Args[2:])
+			return
+		case "rewards":
+			runRewards(os.Args[2:])
+			return
+		case "channels":
+			runChannels(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		}
+	}
+
 	// Set up cmd line flags
 	urlPtr := flag.String("source", defaultURL, "A web URL for accessing the patron data.")
 	cleanPtr := flag.Bool("cleanrun", false, "Set this flag to clear the download cache.")
 	outPtr := flag.String("out", defaultDir, "The directory in which to place the data.json file.")
 	waitPtr := flag.Int64("wait", 5, "An integer value representing the number of minutes to wait between checks.")
+	startPtr := flag.String("start", "", "When the campaign opens, as YYYY-MM-DD or YYYY-MM-DD HH:MM. Nothing is downloaded before then.")
+	closeGracePtr := flag.Duration("closegrace", 72*time.Hour, "How long after -end late-reported pledges are picked up before the campaign is frozen.")
+	campaignsPtr := flag.String("campaigns", "", "A JSON registry of campaigns to run side by side. Each one overrides -source, -price, -layout, -reservations, -capacity, -goals, -start, -end, -closegrace and the output and state locations, and sets its own overlay, payments, matching, recognition and telemetry files.")
+	settings := addPipelineFlags(flag.CommandLine)
 
 	flag.Parse()
 
@@ -39,21 +74,59 @@ func main() {
 
 	logger := logging.NewLogger()
 
-	// If the cleanrun flag is set, delete the current and previous txt files
-	if *cleanPtr {
-		cacheDir := update.GetCacheDir()
-		cacheDir = path.Join(cacheDir, update.AppDir)
-		err := os.Remove(path.Join(cacheDir, update.BaseFileName))
-		if err != nil {
-			logger.Warnln(err)
+	var start time.Time
+	var err error
+	if *startPtr != "" {
+		if start, err = parseDate(*startPtr); err != nil {
+			logger.Fatal(err)
 		}
-		err = os.Remove(path.Join(cacheDir, update.OldFileName))
+	}
+
+	outputPath := path.Join(*outPtr, outputFile)
+	base := settings.options()
+	base.outputPath = outputPath
+	base.stateDir = defaultStateDir()
+
+	var sources []*source
+	if *campaignsPtr != "" {
+		// These files belong to a single campaign, so they have to be set
+		// for each one in the registry.
+		perCampaign := []struct{ name, value string }{
+			{"overlay", *settings.overlay},
+			{"payments", *settings.payments},
+			{"matching", *settings.matching},
+			{"recognition", *settings.recognition},
+			{"telemetry", *settings.telemetry},
+		}
+		for _, setting := range perCampaign {
+			if setting.value != "" {
+				logger.Fatalf("ERROR: -%s can't be used with -campaigns. Set %q for each campaign in the registry instead.\n", setting.name, setting.name)
+			}
+		}
+
+		campaigns, err := campaign.Load(*campaignsPtr)
 		if err != nil {
-			logger.Warnln(err)
+			logger.Fatal(err)
 		}
+		sources = campaignSources(campaigns, base, start, *closeGracePtr, *outPtr, *settings.layout, *settings.fallback)
+	} else {
+		sources = []*source{newSource("", *urlPtr, base, start, *closeGracePtr)}
+		serve.SetDataFile(outputPath)
 	}
 
-	outputPath := path.Join(*outPtr, outputFile)
+	// If the cleanrun flag is set, delete the current and previous txt files
+	if *cleanPtr {
+		for _, src := range sources {
+			err := os.Remove(path.Join(src.pipe.opts.stateDir, update.BaseFileName))
+			if err != nil {
+				logger.Warnln(err)
+			}
+			err = os.Remove(path.Join(src.pipe.opts.stateDir, update.OldFileName))
+			if err != nil {
+				logger.Warnln(err)
+			}
+		}
+	}
 
 	// Start HTTP server on a separate thread to serve the data file.
 	go serve.StartServer()
@@ -72,22 +145,19 @@ func main() {
 		if !timerStop {
 			<-updateTimer.C
 		}
-		fileName := update.CheckForUpdate(*urlPtr)
-
-		// If fileName is not empty, process the data in that file.
-		if fileName != "" {
-			log.Printf("Downloaded file located at: '%s'\n", fileName)
-			// Continue work to process the data.
-			cleanData, _ := data.Clean(fileName)
-			patronList := data.NewPatronList(data.GetPatronData(cleanData))
-			cellList := data.NewCellList(patronList)
-			if err := cellList.ToJSONFile(outputPath); err != nil {
-				logger.Fatal(err)
-			} else {
-				logger.Printf("Data written to %s\n", outputFile)
+		open := 0
+		for _, src := range sources {
+			src.check()
+			if !src.frozen {
+				open++
 			}
-		} else {
-			logger.Printf("Nothing to do. Will check again soon.\n")
+		}
+
+		// Once every campaign is frozen there's nothing left to poll, but
+		// the final data is still served.
+		if open == 0 {
+			logger.Println("Every campaign has closed. Serving the final data.")
+			select {}
 		}
 
 		// Wait for the next check.
@@ -100,3 +170,39 @@ func main() {
 		logger.Printf("Check finished. Waiting %d minute%s...\n", *waitPtr, s)
 	}
 }
+
+// loadLayout loads the layout and reservations files, if any, and checks that
+// the settings which depend on a layout have one.
+func loadLayout(layoutFile, reserveFile, fallback, telemetry string) (*data.Layout, []*data.Reservation) {
+	logger := logging.NewLogger()
+
+	var layout *data.Layout
+	var err error
+	if layoutFile != "" {
+		if layout, err = data.LoadLayout(layoutFile); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	var reservations []*data.Reservation
+	if reserveFile != "" {
+		if layout == nil {
+			logger.Fatalln("ERROR: -reservations can only be used along with -layout.")
+		}
+		if reservations, err = data.LoadReservations(reserveFile, layout); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if telemetry != "" && layout == nil {
+		logger.Fatalln("ERROR: -telemetry can only be used along with -layout.")
+	}
+	if fallback != "" {
+		if layout == nil {
+			logger.Fatalln("ERROR: -fallbackregion can only be used along with -layout.")
+		}
+		if _, ok := layout.Region(fallback); !ok {
+			logger.Fatalf("ERROR: fallback region %q is not in the layout.\n", fallback)
+		}
+	}
+
+	return layout, reservations
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/moderate"
+	"github.com/iAmSomeone2/aacautoupdate/update"
+)
+
+// runModerate implements the "moderate" subcommand, which lets an operator
+// review the names and dedication messages that have been held back from
+// publishing.
+func runModerate(args []string) {
+	flags := flag.NewFlagSet("moderate", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the moderation queue.")
+	msgPtr := flags.Bool("messages", false, "Review dedication messages instead of names.")
+	approvePtr := flags.String("approve", "", "A flagged entry, or its #number from the listing, to approve for publishing.")
+	rejectPtr := flags.String("reject", "", "A flagged entry, or its #number from the listing, to reject. Rejected names are published as anonymous and rejected messages are dropped.")
+	allPtr := flags.Bool("all", false, "List every decision instead of only the pending ones.")
+	flags.Parse(args)
+
+	queueFile := moderate.NamesFile
+	if *msgPtr {
+		queueFile = moderate.MessagesFile
+	}
+
+	queue, err := moderate.LoadQueue(path.Join(*statePtr, queueFile))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *approvePtr != "" || *rejectPtr != "" {
+		if *approvePtr != "" {
+			err = queue.Decide(entryText(queue, *approvePtr), moderate.StatusApproved)
+		}
+		if *rejectPtr != "" && err == nil {
+			err = queue.Decide(entryText(queue, *rejectPtr), moderate.StatusRejected)
+		}
+		if err == nil {
+			err = queue.Save()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !*allPtr && len(queue.Pending()) == 0 {
+		fmt.Println("Nothing waiting for review.")
+		return
+	}
+	for i, entry := range queue.Entries() {
+		if !*allPtr && entry.Status != moderate.StatusPending {
+			continue
+		}
+		fmt.Printf("#%-3d %-9s %-30s %s (%s)\n", i+1, entry.Status, entry.Text, entry.Reason, entry.Flagged.Format("2006-01-02 15:04"))
+	}
+}
+
+// entryText resolves a "#N" reference from the listing into the text of that
+// entry so long messages don't have to be typed out. Anything else is returned
+// as-is.
+func entryText(queue *moderate.Queue, ref string) string {
+	if !strings.HasPrefix(ref, "#") {
+		return ref
+	}
+
+	idx, err := strconv.Atoi(ref[1:])
+	entries := queue.Entries()
+	if err != nil || idx < 1 || idx > len(entries) {
+		return ref
+	}
+	return entries[idx-1].Text
+}
+
+// defaultStateDir returns the directory used for keeping state between runs.
+func defaultStateDir() string {
+	return path.Join(update.GetCacheDir(), update.AppDir)
+}
@@ -0,0 +1,138 @@
+// Package moderate provides a review stage between parsing the patron data and
+// publishing it. Names are checked against a blocklist and a profanity
+// dictionary, and anything that gets flagged is held back until an operator
+// approves it.
+package moderate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Filter holds the rules used for flagging free-text values.
+type Filter struct {
+	substrings []string
+	patterns   []*regexp.Regexp
+	words      map[string]bool
+}
+
+// leetReplacer undoes the most common character swaps used to sneak words past
+// a dictionary check.
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+)
+
+// NewFilter returns a pointer to an empty Filter. Nothing is flagged until a
+// blocklist or dictionary is loaded into it.
+func NewFilter() *Filter {
+	return &Filter{words: make(map[string]bool)}
+}
+
+// LoadBlocklist reads the blocklist file at filePath into the Filter. Each line
+// holds one entry. Entries wrapped in slashes (/like this/) are treated as
+// case-insensitive regular expressions, and everything else is matched as a
+// case-insensitive substring. Blank lines and lines starting with '#' are
+// ignored.
+func (filter *Filter) LoadBlocklist(filePath string) error {
+	lines, err := readLines(filePath)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range lines {
+		if len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			pattern, err := regexp.Compile("(?i)" + line[1:len(line)-1])
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", filePath, i+1, err)
+			}
+			filter.patterns = append(filter.patterns, pattern)
+			continue
+		}
+		filter.substrings = append(filter.substrings, strings.ToLower(line))
+	}
+
+	return nil
+}
+
+// LoadDictionary reads a profanity dictionary with one word per line into the
+// Filter. Dictionary words only match whole words in a name.
+func (filter *Filter) LoadDictionary(filePath string) error {
+	lines, err := readLines(filePath)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		filter.words[strings.ToLower(line)] = true
+	}
+
+	return nil
+}
+
+// Empty reports whether the Filter has no rules loaded.
+func (filter *Filter) Empty() bool {
+	return len(filter.substrings) == 0 && len(filter.patterns) == 0 && len(filter.words) == 0
+}
+
+// Check tests text against every rule in the Filter. If a rule matches, a short
+// reason describing the rule is returned along with true.
+func (filter *Filter) Check(text string) (string, bool) {
+	lower := strings.ToLower(text)
+
+	for _, substr := range filter.substrings {
+		if strings.Contains(lower, substr) {
+			return fmt.Sprintf("blocklist entry %q", substr), true
+		}
+	}
+
+	for _, pattern := range filter.patterns {
+		if pattern.MatchString(text) {
+			return fmt.Sprintf("blocklist pattern /%s/", strings.TrimPrefix(pattern.String(), "(?i)")), true
+		}
+	}
+
+	// Split on anything that isn't a letter or one of the leet characters so
+	// that "J0hn-Smith" is checked as "john" and "smith".
+	fields := strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !strings.ContainsRune("013457@$", r)
+	})
+	for _, field := range fields {
+		if filter.words[field] || filter.words[leetReplacer.Replace(field)] {
+			return fmt.Sprintf("dictionary word %q", field), true
+		}
+	}
+
+	return "", false
+}
+
+// readLines returns the trimmed, non-comment lines of the file at filePath.
+func readLines(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,50 @@
+package moderate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/moderate"
+)
+
+func TestFilterCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moderate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocklist := path.Join(dir, "blocklist.txt")
+	dictionary := path.Join(dir, "profanity.txt")
+	ioutil.WriteFile(blocklist, []byte("# comment\nspam\n/^test\\s+user$/\n"), 0644)
+	ioutil.WriteFile(dictionary, []byte("darn\n"), 0644)
+
+	filter := moderate.NewFilter()
+	if err := filter.LoadBlocklist(blocklist); err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.LoadDictionary(dictionary); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"Jane Smith":     false,
+		"Spammy McSpam":  true,
+		"Test User":      true,
+		"Test Username":  false,
+		"D4rn It":        true,
+		"Darnell Jones":  false,
+		"Anonymous Darn": true,
+	}
+	for name, expected := range cases {
+		if _, flagged := filter.Check(name); flagged != expected {
+			t.Error(
+				"For", name,
+				"expected", expected,
+				"got", flagged,
+			)
+		}
+	}
+}
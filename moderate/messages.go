@@ -0,0 +1,64 @@
+package moderate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// MessagesFile is the name of the file used for storing dedication decisions.
+const MessagesFile string = "messages.json"
+
+// ScreenMessages runs every dedication message through moderation. Messages
+// longer than maxLen characters are cut down at a word boundary first. Every
+// message waits in the queue for an operator unless autoApprove is set, in
+// which case only messages the filter flags are held. The number of newly
+// queued messages is returned.
+func ScreenMessages(patrons []*data.Patron, filter *Filter, queue *Queue, maxLen int, autoApprove bool) int {
+	queued := 0
+
+	for _, patron := range patrons {
+		text := patron.Message()
+		if text == "" {
+			continue
+		}
+		if maxLen > 0 && len([]rune(text)) > maxLen {
+			text = truncate(text, maxLen)
+			patron.SetMessage(text)
+		}
+
+		entry, known := queue.Lookup(text)
+		if !known {
+			reason, flagged := filter.Check(text)
+			if !flagged && autoApprove {
+				patron.SetMessageState(data.MessageApproved)
+				continue
+			}
+			if !flagged {
+				reason = "awaiting approval"
+			}
+			entry = queue.Flag(text, reason)
+			queued++
+		}
+
+		patron.SetMessageState(string(entry.Status))
+	}
+
+	return queued
+}
+
+// truncate shortens text to at most maxLen characters, backing up to the last
+// space so words aren't split, and marks the cut with an ellipsis.
+func truncate(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+
+	cut := string(runes[:maxLen-1])
+	if idx := strings.LastIndex(cut, " "); idx > 0 {
+		cut = cut[:idx]
+	}
+	return fmt.Sprintf("%s…", strings.TrimSpace(cut))
+}
@@ -0,0 +1,92 @@
+package moderate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/moderate"
+)
+
+func TestScreenMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moderate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dictionary := path.Join(dir, "profanity.txt")
+	ioutil.WriteFile(dictionary, []byte("darn\n"), 0644)
+	filter := moderate.NewFilter()
+	if err := filter.LoadDictionary(dictionary); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := moderate.LoadQueue(path.Join(dir, moderate.MessagesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Decide("For my mother", moderate.StatusRejected); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		message     string
+		autoApprove bool
+		state       string
+	}{
+		{"", true, ""},
+		{"For the kids", true, data.MessageApproved},
+		{"Darn good cause", true, string(moderate.StatusPending)},
+		{"For the planet", false, string(moderate.StatusPending)},
+		{"for  my MOTHER", true, string(moderate.StatusRejected)},
+	}
+	var patrons []*data.Patron
+	for i, test := range tests {
+		patron := data.NewPatron(i+1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+		if test.message != "" {
+			patron.SetMessage(test.message)
+		}
+		patrons = append(patrons, patron)
+	}
+
+	queued := 0
+	for i, test := range tests {
+		queued += moderate.ScreenMessages(patrons[i:i+1], filter, queue, 0, test.autoApprove)
+		if state := patrons[i].MessageState(); state != test.state {
+			t.Error("For", test.message, "expected", test.state, "got", state)
+		}
+	}
+	if queued != 2 {
+		t.Error("For", "queued messages", "expected", 2, "got", queued)
+	}
+}
+
+func TestScreenMessagesTruncates(t *testing.T) {
+	tests := []struct {
+		message  string
+		maxLen   int
+		expected string
+	}{
+		{"In memory of Grandpa Joe", 0, "In memory of Grandpa Joe"},
+		{"In memory of Grandpa Joe", 24, "In memory of Grandpa Joe"},
+		{"In memory of Grandpa Joe", 20, "In memory of…"},
+		{"Inmemoryofgrandpajoe", 10, "Inmemoryo…"},
+		{"Für Oma Gisela", 9, "Für Oma…"},
+	}
+	for _, test := range tests {
+		patron := data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50)
+		patron.SetMessage(test.message)
+		queue, _ := moderate.LoadQueue(path.Join(os.TempDir(), "missing", moderate.MessagesFile))
+		moderate.ScreenMessages([]*data.Patron{patron}, moderate.NewFilter(), queue, test.maxLen, true)
+
+		if message := patron.Message(); message != test.expected {
+			t.Error("For", test.message, test.maxLen, "expected", test.expected, "got", message)
+		}
+		if length := len([]rune(patron.Message())); test.maxLen > 0 && length > test.maxLen {
+			t.Error("For", test.message, "expected", "at most", test.maxLen, "characters", "got", length)
+		}
+	}
+}
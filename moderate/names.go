@@ -0,0 +1,60 @@
+package moderate
+
+import (
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// NamesFile is the name of the file used for storing name decisions.
+const NamesFile string = "names.json"
+
+// redactor is implemented by anything holding a name that moderation can hide.
+// Both Patrons and the honorees of their Gifts satisfy it.
+type redactor interface {
+	HoldForReview()
+	Redact()
+}
+
+// ScreenNames runs every non-anonymous Patron's name, and the name of any gift
+// honoree, through the filter. Names that are flagged and haven't been approved
+// are replaced with "Pending Review" and added to the queue. Names the operator
+// rejected are redacted. The number of newly flagged names is returned so the
+// caller knows if the queue needs to be saved.
+func ScreenNames(patrons []*data.Patron, filter *Filter, queue *Queue) int {
+	flagged := 0
+
+	for _, patron := range patrons {
+		if !patron.Anonymous() && screenName(patron.Name(), patron, filter, queue) {
+			flagged++
+		}
+
+		gift := patron.Gift()
+		if gift != nil && !gift.Anonymous() && screenName(gift.Honoree(), gift, filter, queue) {
+			flagged++
+		}
+	}
+
+	return flagged
+}
+
+// screenName applies the queue's decision for name to target. True is returned
+// if name was newly added to the queue.
+func screenName(name string, target redactor, filter *Filter, queue *Queue) bool {
+	entry, known := queue.Lookup(name)
+	if !known {
+		reason, bad := filter.Check(name)
+		if !bad {
+			return false
+		}
+		entry = queue.Flag(name, reason)
+	}
+
+	switch entry.Status {
+	case StatusApproved:
+	case StatusRejected:
+		target.Redact()
+	default:
+		target.HoldForReview()
+	}
+
+	return !known
+}
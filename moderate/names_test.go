@@ -0,0 +1,67 @@
+package moderate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/moderate"
+)
+
+func TestScreenNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moderate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocklist := path.Join(dir, "blocklist.txt")
+	ioutil.WriteFile(blocklist, []byte("spam\nscam\n"), 0644)
+	filter := moderate.NewFilter()
+	if err := filter.LoadBlocklist(blocklist); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := moderate.LoadQueue(path.Join(dir, moderate.NamesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.Flag("Spam Approved", "blocklist")
+	queue.Decide("Spam Approved", moderate.StatusApproved)
+	queue.Decide("Scam Rejected", moderate.StatusRejected)
+
+	tests := []struct {
+		first, last string
+		anon        bool
+		expected    string
+	}{
+		{"Jane", "Smith", false, "Jane Smith"},
+		{"Spammy", "Person", false, "Pending Review"},
+		{"Spam", "Approved", false, "Spam Approved"},
+		{"Scam", "Rejected", false, "Anonymous Donor"},
+		{"Spam", "Hidden", true, "Anonymous Donor"},
+	}
+	var patrons []*data.Patron
+	for i, test := range tests {
+		patrons = append(patrons, data.NewPatron(i+1, "2019-03-31 08:21:16", test.anon, test.first, test.last, 50))
+	}
+
+	if flagged := moderate.ScreenNames(patrons, filter, queue); flagged != 1 {
+		t.Error("For", "newly flagged names", "expected", 1, "got", flagged)
+	}
+	for i, test := range tests {
+		if name := patrons[i].Name(); name != test.expected {
+			t.Error("For", test.first, test.last, "expected", test.expected, "got", name)
+		}
+	}
+
+	// Screening again doesn't flag the same name twice.
+	if flagged := moderate.ScreenNames(patrons, filter, queue); flagged != 0 {
+		t.Error("For", "second screening", "expected", 0, "got", flagged)
+	}
+	if pending := len(queue.Pending()); pending != 1 {
+		t.Error("For", "pending names", "expected", 1, "got", pending)
+	}
+}
@@ -0,0 +1,148 @@
+package moderate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the review state of a flagged entry.
+type Status string
+
+const (
+	// StatusPending is used for entries that are waiting on an operator.
+	StatusPending Status = "pending"
+	// StatusApproved is used for entries that may be published as-is.
+	StatusApproved Status = "approved"
+	// StatusRejected is used for entries that must never be published.
+	StatusRejected Status = "rejected"
+)
+
+// Entry is a single flagged value and the decision made about it.
+type Entry struct {
+	Text    string    `json:"text"`
+	Status  Status    `json:"status"`
+	Reason  string    `json:"reason"`
+	Flagged time.Time `json:"flagged"`
+	Decided time.Time `json:"decided,omitempty"`
+}
+
+// Queue is the on-disk record of everything that has been flagged and what the
+// operator decided about it. Entries are keyed by their normalized text so a
+// decision applies to every pledge using the same value.
+type Queue struct {
+	filePath string
+	entries  map[string]*Entry
+}
+
+// LoadQueue reads the queue stored at filePath. A missing file is not an error
+// and results in an empty Queue that will be created on the first Save().
+func LoadQueue(filePath string) (*Queue, error) {
+	queue := &Queue{filePath: filePath, entries: make(map[string]*Entry)}
+
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	for _, entry := range entries {
+		queue.entries[normalize(entry.Text)] = entry
+	}
+
+	return queue, nil
+}
+
+// Save writes the contents of the Queue back to its file.
+func (queue *Queue) Save() error {
+	err := os.MkdirAll(path.Dir(queue.filePath), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(queue.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(queue.filePath, data, 0644)
+}
+
+// Lookup returns the Entry for text, if one exists.
+func (queue *Queue) Lookup(text string) (*Entry, bool) {
+	entry, ok := queue.entries[normalize(text)]
+	return entry, ok
+}
+
+// Flag adds text to the Queue as a pending entry. If text is already in the
+// Queue, the existing Entry is returned untouched so earlier decisions stick.
+func (queue *Queue) Flag(text, reason string) *Entry {
+	if entry, ok := queue.Lookup(text); ok {
+		return entry
+	}
+
+	entry := &Entry{
+		Text:    text,
+		Status:  StatusPending,
+		Reason:  reason,
+		Flagged: time.Now(),
+	}
+	queue.entries[normalize(text)] = entry
+	return entry
+}
+
+// Decide records the operator's decision for text. Text that was never flagged
+// can still be decided ahead of time, which is useful for pre-approving names.
+func (queue *Queue) Decide(text string, status Status) error {
+	if status != StatusApproved && status != StatusRejected && status != StatusPending {
+		return fmt.Errorf("unknown moderation status %q", status)
+	}
+
+	entry := queue.Flag(text, "manual decision")
+	entry.Status = status
+	entry.Decided = time.Now()
+	return nil
+}
+
+// Entries returns every Entry in the Queue, oldest first.
+func (queue *Queue) Entries() []*Entry {
+	entries := make([]*Entry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Flagged.Equal(entries[j].Flagged) {
+			return entries[i].Text < entries[j].Text
+		}
+		return entries[i].Flagged.Before(entries[j].Flagged)
+	})
+	return entries
+}
+
+// Pending returns every Entry still waiting on a decision, oldest first.
+func (queue *Queue) Pending() []*Entry {
+	var pending []*Entry
+	for _, entry := range queue.Entries() {
+		if entry.Status == StatusPending {
+			pending = append(pending, entry)
+		}
+	}
+	return pending
+}
+
+// normalize folds text down so that trivial differences in spacing and case
+// don't require a second review.
+func normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/alert"
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/campaign"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/logging"
+	"github.com/iAmSomeone2/aacautoupdate/moderate"
+	"github.com/iAmSomeone2/aacautoupdate/refund"
+	"github.com/iAmSomeone2/aacautoupdate/reward"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+	"github.com/iAmSomeone2/aacautoupdate/telemetry"
+	"github.com/iAmSomeone2/aacautoupdate/update"
+)
+
+// privateFile is the name of the file in the state directory that holds the
+// full patron records, including contact details and pledge history.
+const privateFile string = "patrons_private.json"
+
+// alertsFile is the name of the file in the state directory that remembers
+// which alerts have already fired.
+const alertsFile string = "alerts.json"
+
+// allocationsFile is the name of the file in the state directory that holds
+// the positions each owner was given, so that allocations stay stable.
+const allocationsFile string = "allocations.json"
+
+// transfersFile is the name of the file in the state directory that holds the
+// history of adoptions moved between cells.
+const transfersFile string = "transfers.json"
+
+// carryoverFile is the name of the file in the state directory that holds the
+// leftover credit being carried into the next campaign.
+const carryoverFile string = "carryover.json"
+
+// options holds the settings a pipeline is built from. Any of the file paths
+// may be left empty to skip that stage.
+type options struct {
+	outputPath  string
+	stateDir    string
+	blocklist   string
+	profanity   string
+	overlay     string
+	maxMessage  int
+	autoApprove bool
+	matchRules  []data.MatchRule
+	refunds     refund.Policy
+	layout      *data.Layout
+	reserved    []*data.Reservation
+	fill        data.FillOrder
+	fallback    string
+	credit      data.CreditPolicy
+	carryIn     string
+	capacity    int
+	overflow    data.OverflowMode
+	block       data.BlockMode
+	noScatter   bool
+	thresholds  []float64
+	telemetry   string
+	cellPrice   int
+	payments    string
+	grace       time.Duration
+	matching    []*data.MatchingGift
+	goal        *data.Goal
+	recognition *data.Recognition
+	end         time.Time
+	signingKey  string
+}
+
+// pipelineFlags are the command line flags the pipeline options are built
+// from. The report subcommands take the same flags as the updater, so that
+// they see the same pledges as the published data.
+type pipelineFlags struct {
+	blocklist    *string
+	profanity    *string
+	overlay      *string
+	maxMessage   *int
+	autoApprove  *bool
+	refunds      *string
+	payments     *string
+	grace        *time.Duration
+	matching     *string
+	goals        *string
+	recognition  *string
+	end          *string
+	signingKey   *string
+	layout       *string
+	reservations *string
+	telemetry    *string
+	fallback     *string
+	fill         *string
+	block        *string
+	noScatter    *bool
+	capacity     *int
+	overflow     *string
+	alerts       *string
+	credit       *string
+	carryIn      *string
+	price        *int
+	dedupe       *string
+}
+
+// addPipelineFlags defines the pipeline's flags on flags.
+func addPipelineFlags(flags *flag.FlagSet) *pipelineFlags {
+	return &pipelineFlags{
+		blocklist:    flags.String("blocklist", "", "A file of blocked words and /patterns/ that hold names for review."),
+		profanity:    flags.String("profanity", "", "A dictionary file of words that hold names for review."),
+		overlay:      flags.String("overlay", "", "A JSON file of extra pledge details, such as dedications or gift honorees, to merge into the export."),
+		maxMessage:   flags.Int("maxmessage", 140, "The maximum number of characters allowed in a dedication message."),
+		autoApprove:  flags.Bool("autoapprove", false, "Publish dedication messages that pass the filters without waiting for review."),
+		refunds:      flags.String("refunds", string(refund.PolicyReview), "What to do with the cells of refunded or cancelled pledges: release, keep or review."),
+		payments:     flags.String("payments", "", "A CSV reconciliation file giving the payment status of pledges: pledged, collected or failed."),
+		grace:        flags.Duration("paymentgrace", 0, "How long cells of a pledge with a failed payment stay pending before they're released, e.g. \"72h\"."),
+		matching:     flags.String("matching", "", "A JSON file of matching gift rules from sponsors."),
+		goals:        flags.String("goals", "", "A JSON file with the campaign goal, in dollars or cells, and its milestones."),
+		recognition:  flags.String("recognition", "", "A JSON file setting the size of the donor leaderboards and the recognition tiers, with their rewards. Without it, no leaderboards or tiers are published."),
+		end:          flags.String("end", "", "When the campaign closes, as YYYY-MM-DD or YYYY-MM-DD HH:MM. Later pledges are ignored."),
+		signingKey:   flags.String("signingkey", defaultKeyFile(), "The private key that signs the archive of a closed campaign. It's created if missing and must be kept outside of the state directory."),
+		layout:       flags.String("layout", "", "A JSON file describing the physical cell array."),
+		reservations: flags.String("reservations", "", "A JSON file of cells held back for sponsors. Requires -layout."),
+		telemetry:    flags.String("telemetry", "", "A glob of CSV telemetry exports, per module or string, used to estimate each cell's energy. Requires -layout."),
+		fallback:     flags.String("fallbackregion", "", "The layout region or module used when a placement request can't be met."),
+		fill:         flags.String("fill", string(data.FillRowMajor), "The order cells are filled on the array: row-major, spiral or module."),
+		block:        flags.String("block", string(data.BlockScattered), "How a donor's cells are grouped on the array: scattered, rect or module."),
+		noScatter:    flags.Bool("noscatter", false, "Waitlist a donor's cells instead of scattering them when no block fits."),
+		capacity:     flags.Int("capacity", 0, "The most cells that may be adopted. Defaults to the size of the layout, or unlimited without one."),
+		overflow:     flags.String("overflow", string(data.OverflowWaitlist), "How adoptions beyond capacity are listed: waitlist or honorary."),
+		alerts:       flags.String("alerts", "80,95,100", "Comma separated percentages of capacity that raise an alert when reached."),
+		credit:       flags.String("credit", string(data.CreditHold), "What to do with pledges that don't add up to a cell: hold, community, partial or carry."),
+		carryIn:      flags.String("carryin", "", "A carryover.json file from an earlier campaign whose credit should be added to this one."),
+		price:        flags.Int("price", data.DefaultCellPrice, "The price of a single cell in dollars."),
+		dedupe:       flags.String("dedupe", data.DefaultMatchRules, "Rules for merging repeat donors, e.g. \"email;name+zip\". Leave empty to disable."),
+	}
+}
+
+// options loads and checks the files and settings named by the flags. The
+// output path and state directory are left for the caller to set.
+func (settings *pipelineFlags) options() options {
+	logger := logging.NewLogger()
+
+	matchRules, err := data.ParseMatchRules(*settings.dedupe)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	refundPolicy, err := refund.ParsePolicy(*settings.refunds)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fill, err := data.ParseFillOrder(*settings.fill)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	layout, reservations := loadLayout(*settings.layout, *settings.reservations, *settings.fallback, *settings.telemetry)
+
+	block, err := data.ParseBlockMode(*settings.block)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	overflow, err := data.ParseOverflowMode(*settings.overflow)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	credit, err := data.ParseCreditPolicy(*settings.credit)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	thresholds, err := alert.ParseThresholds(*settings.alerts)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var matching []*data.MatchingGift
+	if *settings.matching != "" {
+		if matching, err = data.LoadMatchingGifts(*settings.matching); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var goal *data.Goal
+	if *settings.goals != "" {
+		if goal, err = data.LoadGoal(*settings.goals); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var recognition *data.Recognition
+	if *settings.recognition != "" {
+		if recognition, err = data.LoadRecognition(*settings.recognition); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var end time.Time
+	if *settings.end != "" {
+		if end, err = parseDate(*settings.end); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if *settings.price <= 0 {
+		logger.Fatalln("ERROR: -price must be more than 0.")
+	}
+
+	return options{
+		blocklist:   *settings.blocklist,
+		profanity:   *settings.profanity,
+		overlay:     *settings.overlay,
+		maxMessage:  *settings.maxMessage,
+		autoApprove: *settings.autoApprove,
+		matchRules:  matchRules,
+		refunds:     refundPolicy,
+		layout:      layout,
+		reserved:    reservations,
+		fill:        fill,
+		fallback:    *settings.fallback,
+		credit:      credit,
+		carryIn:     *settings.carryIn,
+		capacity:    *settings.capacity,
+		overflow:    overflow,
+		block:       block,
+		noScatter:   *settings.noScatter,
+		thresholds:  thresholds,
+		telemetry:   *settings.telemetry,
+		cellPrice:   *settings.price,
+		payments:    *settings.payments,
+		grace:       *settings.grace,
+		matching:    matching,
+		goal:        goal,
+		recognition: recognition,
+		end:         end,
+		signingKey:  *settings.signingKey,
+	}
+}
+
+// pipeline holds everything needed for turning a downloaded patron file into
+// the published data.json file.
+type pipeline struct {
+	opts    options
+	final   bool
+	filter  *moderate.Filter
+	lastRun time.Time
+	logger  *logging.Logger
+}
+
+// newPipeline sets up a pipeline from opts.
+func newPipeline(opts options) *pipeline {
+	logger := logging.NewLogger()
+
+	filter := moderate.NewFilter()
+	if opts.blocklist != "" {
+		if err := filter.LoadBlocklist(opts.blocklist); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if opts.profanity != "" {
+		if err := filter.LoadDictionary(opts.profanity); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	return &pipeline{
+		opts:   opts,
+		filter: filter,
+		logger: logger,
+	}
+}
+
+// stateChanged reports whether any operator decisions, overlay entries or
+// telemetry exports have been saved since the last run. If so, the data needs
+// to be published again even though the download itself hasn't changed.
+func (pipe *pipeline) stateChanged() bool {
+	watched := []string{
+		path.Join(pipe.opts.stateDir, moderate.NamesFile),
+		path.Join(pipe.opts.stateDir, moderate.MessagesFile),
+		path.Join(pipe.opts.stateDir, refund.DecisionsFile),
+		path.Join(pipe.opts.stateDir, transfersFile),
+	}
+	if pipe.opts.overlay != "" {
+		watched = append(watched, pipe.opts.overlay)
+	}
+	if pipe.opts.payments != "" {
+		watched = append(watched, pipe.opts.payments)
+	}
+	if pipe.opts.telemetry != "" {
+		exports, _ := filepath.Glob(pipe.opts.telemetry)
+		watched = append(watched, exports...)
+	}
+
+	for _, fileName := range watched {
+		info, err := os.Stat(fileName)
+		if err == nil && info.ModTime().After(pipe.lastRun) {
+			return true
+		}
+	}
+	return false
+}
+
+// run processes the patron data in fileName and writes the result to the
+// pipeline's output path.
+func (pipe *pipeline) run(fileName string) error {
+	patrons, err := pipe.patrons(fileName, true)
+	if err != nil {
+		return err
+	}
+
+	// Hold back any names that haven't passed moderation.
+	names, err := moderate.LoadQueue(path.Join(pipe.opts.stateDir, moderate.NamesFile))
+	if err != nil {
+		return err
+	}
+	if flagged := moderate.ScreenNames(patrons, pipe.filter, names); flagged > 0 {
+		pipe.logger.Printf("%d new name(s) flagged for review.\n", flagged)
+		if err := names.Save(); err != nil {
+			return err
+		}
+	}
+
+	// Dedication messages go through their own queue.
+	messages, err := moderate.LoadQueue(path.Join(pipe.opts.stateDir, moderate.MessagesFile))
+	if err != nil {
+		return err
+	}
+	queued := moderate.ScreenMessages(patrons, pipe.filter, messages, pipe.opts.maxMessage, pipe.opts.autoApprove)
+	if queued > 0 {
+		pipe.logger.Printf("%d new dedication(s) waiting for review.\n", queued)
+		if err := messages.Save(); err != nil {
+			return err
+		}
+	}
+
+	patronList := data.NewPatronList(patrons)
+	if err := patronList.ToPrivateJSONFile(path.Join(pipe.opts.stateDir, privateFile)); err != nil {
+		return err
+	}
+	if pipe.opts.recognition != nil && pipe.opts.recognition.HasRewards() {
+		rewards, err := reward.Load(pipe.opts.stateDir)
+		if err != nil {
+			return err
+		}
+		rewards.Sync(patronList.Patrons(), pipe.opts.recognition)
+		if err := rewards.Save(); err != nil {
+			return err
+		}
+	}
+	cellList, assignments, err := pipe.cellList(patronList)
+	if err != nil {
+		return err
+	}
+	if pipe.opts.credit == data.CreditCarry {
+		if err := writeJSON(path.Join(pipe.opts.stateDir, carryoverFile), cellList.Carryover()); err != nil {
+			return err
+		}
+	}
+	if report := cellList.PlacementReport(); len(report) > 0 {
+		pipe.logger.Printf("Placement requests: %d honored, %d fallback, %d unavailable.\n",
+			report[data.PlacementHonored], report[data.PlacementFallback], report[data.PlacementUnavailable])
+	}
+	if pipe.opts.goal != nil {
+		if err := pipe.checkMilestones(cellList); err != nil {
+			return err
+		}
+	}
+	cellList.SetRecognition(pipe.opts.recognition)
+	if pipe.final {
+		cellList.SetFinal(time.Now())
+	}
+	if err := cellList.ToJSONFile(pipe.opts.outputPath); err != nil {
+		return err
+	}
+	if pipe.opts.layout != nil {
+		if err := writeJSON(path.Join(pipe.opts.stateDir, allocationsFile), assignments); err != nil {
+			return err
+		}
+	}
+	if err := pipe.checkCapacity(cellList); err != nil {
+		return err
+	}
+	snapshot := stats.Snapshot{Time: time.Now(), Totals: cellList.Totals()}
+	history, err := stats.AppendHistory(pipe.opts.stateDir, snapshot)
+	if err != nil {
+		return err
+	}
+	pledges := cellList.Pledges()
+	published := stats.Build(snapshot, history, pledges)
+	published.Forecast = newForecast(cellList, pipe.opts.goal, pipe.opts.end, stats.DefaultWindow, pipe.opts.cellPrice)
+	published.Channels = stats.PublicChannels(pledges, pipe.opts.cellPrice)
+	if err := published.Write(path.Join(path.Dir(pipe.opts.outputPath), stats.FileName)); err != nil {
+		return err
+	}
+
+	pipe.lastRun = time.Now()
+	return nil
+}
+
+// patrons reads the patron data in fileName and prepares it the same way for
+// every run: late pledges are dropped, the overlay, payments and refund policy
+// are applied, and repeat donors are merged before any carried credit is
+// added. The refund tracker is only saved if save is set, so that reports can
+// prepare the same patrons without changing the state directory.
+func (pipe *pipeline) patrons(fileName string, save bool) ([]*data.Patron, error) {
+	cleanData, err := data.Clean(fileName)
+	if err != nil {
+		return nil, err
+	}
+	patrons := data.GetPatronData(cleanData)
+
+	// Pledges made after the campaign closed don't count toward it.
+	if !pipe.opts.end.IsZero() {
+		var inTime []*data.Patron
+		for _, patron := range patrons {
+			if !patron.PledgeTime().After(pipe.opts.end) {
+				inTime = append(inTime, patron)
+			}
+		}
+		if late := len(patrons) - len(inTime); late > 0 {
+			pipe.logger.Printf("Ignored %d pledge(s) made after the campaign closed.\n", late)
+		}
+		patrons = inTime
+	}
+
+	// Fill in anything the export doesn't provide.
+	if pipe.opts.overlay != "" {
+		overlay, err := data.LoadOverlay(pipe.opts.overlay)
+		if err != nil {
+			return nil, err
+		}
+		overlay.Apply(patrons)
+	}
+
+	// Payments checked against the processor override the export.
+	if pipe.opts.payments != "" {
+		recon, err := data.LoadReconciliation(pipe.opts.payments)
+		if err != nil {
+			return nil, err
+		}
+		recon.Apply(patrons)
+	}
+
+	// Apply the refund policy to anything refunded or missing since last time.
+	tracker, err := refund.Load(pipe.opts.stateDir, pipe.opts.refunds)
+	if err != nil {
+		return nil, err
+	}
+	tracker.SetGracePeriod(pipe.opts.grace)
+	if !save {
+		tracker.Preview()
+	}
+	if patrons, err = tracker.Apply(patrons); err != nil {
+		return nil, err
+	}
+	if save {
+		if err := tracker.Save(); err != nil {
+			return nil, err
+		}
+	}
+	if review := len(tracker.UnderReview()); review > 0 {
+		pipe.logger.Printf("%d refunded or cancelled pledge(s) waiting for review.\n", review)
+	}
+
+	// Work out cell amounts at this campaign's price.
+	for _, patron := range patrons {
+		patron.SetCellPrice(pipe.opts.cellPrice)
+	}
+
+	// Installments of a recurring donation always count as one pledge.
+	patrons = data.GroupInstallments(patrons)
+
+	// Combine repeat donors before anything is published or allocated.
+	pledgeCount := len(patrons)
+	patrons = data.MergeRepeatDonors(patrons, pipe.opts.matchRules)
+	if merged := pledgeCount - len(patrons); merged > 0 {
+		pipe.logger.Printf("Merged %d repeat pledge(s) into existing donors.\n", merged)
+	}
+
+	// Add any credit carried over from an earlier campaign. It's added after
+	// the refund tracker so it's never mistaken for one of this campaign's
+	// pledges, and after merging so it stays separate from new pledges.
+	if pipe.opts.carryIn != "" {
+		var carried []data.Carryover
+		if err := readJSON(pipe.opts.carryIn, &carried); err != nil {
+			return nil, err
+		}
+		for _, patron := range data.CarriedPatrons(carried, len(patrons)+1) {
+			patron.SetCellPrice(pipe.opts.cellPrice)
+			patrons = append(patrons, patron)
+		}
+	}
+
+	return patrons, nil
+}
+
+// cellList allocates cells to the patrons in patronList with the pipeline's
+// settings, keeping the positions owners were given before. It returns the
+// assignments, which include any new positions.
+func (pipe *pipeline) cellList(patronList *data.PatronList) (*data.CellList, map[string][]int, error) {
+	assignments := make(map[string][]int)
+	if err := readJSON(path.Join(pipe.opts.stateDir, allocationsFile), &assignments); err != nil {
+		return nil, nil, err
+	}
+	var moves []data.Move
+	if err := readJSON(path.Join(pipe.opts.stateDir, transfersFile), &moves); err != nil {
+		return nil, nil, err
+	}
+	cellList := data.NewCellList(patronList, &data.CellOptions{
+		Layout:         pipe.opts.layout,
+		Fill:           pipe.opts.fill,
+		Capacity:       pipe.opts.capacity,
+		Overflow:       pipe.opts.overflow,
+		Block:          pipe.opts.block,
+		NoScatter:      pipe.opts.noScatter,
+		Assignments:    assignments,
+		Reservations:   pipe.opts.reserved,
+		FallbackRegion: pipe.opts.fallback,
+		Credit:         pipe.opts.credit,
+		Moves:          moves,
+		MatchingGifts:  pipe.opts.matching,
+	})
+	if pipe.opts.telemetry != "" {
+		readings, err := telemetry.Load(pipe.opts.telemetry)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellList.SetEnergy(readings.CellEnergy(pipe.opts.layout))
+	}
+	return cellList, assignments, nil
+}
+
+// reportFlags are the flags of the report subcommands. Along with the
+// updater's own flags, they choose the export to report on and, for a
+// registry of campaigns, which campaign it belongs to.
+type reportFlags struct {
+	*pipelineFlags
+	state     *string
+	export    *string
+	campaigns *string
+	campaign  *string
+}
+
+// addReportFlags defines the report flags on flags.
+func addReportFlags(flags *flag.FlagSet) *reportFlags {
+	return &reportFlags{
+		pipelineFlags: addPipelineFlags(flags),
+		state:         flags.String("state", defaultStateDir(), "The directory holding the downloaded campaign export."),
+		export:        flags.String("export", "", "The campaign export to report on. Defaults to the last download in the state directory."),
+		campaigns:     flags.String("campaigns", "", "The JSON registry of campaigns the updater runs. The campaign's own settings and state directory are used."),
+		campaign:      flags.String("campaign", "", "The slug of the campaign in -campaigns to report on."),
+	}
+}
+
+// cellList builds the CellList a run would publish from the export, along
+// with the options it was built with. Nothing in the state directory is
+// changed.
+func (settings *reportFlags) cellList() (*data.CellList, options, error) {
+	opts := settings.options()
+	opts.stateDir = *settings.state
+	if *settings.campaigns != "" {
+		campaigns, err := campaign.Load(*settings.campaigns)
+		if err != nil {
+			return nil, opts, err
+		}
+		var found *campaign.Campaign
+		for _, c := range campaigns {
+			if c.Slug == *settings.campaign {
+				found = c
+			}
+		}
+		if found == nil {
+			return nil, opts, fmt.Errorf("-campaign must name a campaign in %s", *settings.campaigns)
+		}
+		opts = campaignOptions(found, opts, campaignStateDirs(campaigns), "", *settings.layout, *settings.fallback)
+	}
+
+	exportFile := *settings.export
+	if exportFile == "" {
+		exportFile = path.Join(opts.stateDir, update.BaseFileName)
+	}
+	pipe := newPipeline(opts)
+	patrons, err := pipe.patrons(exportFile, false)
+	if err != nil {
+		return nil, opts, err
+	}
+	cellList, _, err := pipe.cellList(data.NewPatronList(patrons))
+	return cellList, opts, err
+}
+
+// checkCapacity raises an alert the first time the array passes each of the
+// configured thresholds. Thresholds re-arm if the array drops back below them.
+func (pipe *pipeline) checkCapacity(cellList *data.CellList) error {
+	alerts, err := alert.Load(path.Join(pipe.opts.stateDir, alertsFile))
+	if err != nil {
+		return err
+	}
+	log := audit.Open(path.Join(pipe.opts.stateDir, audit.FileName))
+
+	percent := cellList.PercentFull()
+	for _, threshold := range pipe.opts.thresholds {
+		key := fmt.Sprintf("capacity-%g", threshold)
+		if percent < threshold {
+			alerts.Rearm(key)
+			continue
+		}
+		if alerts.Fire(key) {
+			pipe.logger.Warnf("Cell array is %.1f%% full, passing the %g%% threshold.\n", percent, threshold)
+			if err := log.Record("capacity-alert", key, fmt.Sprintf("%.1f%% full", percent)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return alerts.Save()
+}
+
+// checkMilestones announces each goal milestone the first time it's reached,
+// and attaches the goal's progress to the published data. A milestone never
+// fires twice, even if refunds take the campaign back below it, but it's only
+// published as reached while it still is.
+func (pipe *pipeline) checkMilestones(cellList *data.CellList) error {
+	alerts, err := alert.Load(path.Join(pipe.opts.stateDir, alertsFile))
+	if err != nil {
+		return err
+	}
+	log := audit.Open(path.Join(pipe.opts.stateDir, audit.FileName))
+
+	goal := pipe.opts.goal
+	raised := cellList.Raised(goal.Unit)
+	reached := make(map[string]time.Time)
+	for _, milestone := range goal.All() {
+		key := "milestone-" + milestone.Name
+		if raised >= milestone.Amount && alerts.Fire(key) {
+			pipe.logger.Printf("Milestone reached: %s (%g %s).\n", milestone.Name, milestone.Amount, goal.Unit)
+			if err := log.Record("milestone", milestone.Name, fmt.Sprintf("%g of %g %s", raised, goal.Target, goal.Unit)); err != nil {
+				return err
+			}
+		}
+		if firedAt, ok := alerts.Fired(key); ok {
+			reached[milestone.Name] = firedAt
+		}
+	}
+	cellList.SetGoal(goal, reached)
+
+	return alerts.Save()
+}
+
+// readJSON unmarshals the state file at fileName into v. A missing file leaves
+// v untouched.
+func readJSON(fileName string, v interface{}) error {
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	return nil
+}
+
+// writeJSON marshals v into the state file at fileName.
+func writeJSON(fileName string, v interface{}) error {
+	err := os.MkdirAll(path.Dir(fileName), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, 0644)
+}
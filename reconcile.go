@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/reconcile"
+	"github.com/iAmSomeone2/aacautoupdate/update"
+)
+
+// runReconcile implements the "reconcile" subcommand, which matches the
+// pledges in the campaign export against a payment export and writes a report
+// of what was and wasn't received.
+func runReconcile(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the downloaded campaign export.")
+	exportPtr := flags.String("export", "", "The campaign export to reconcile. Defaults to the last download in the state directory.")
+	paymentsPtr := flags.String("payments", "", "A CSV export from Stripe, PayPal or the bank.")
+	windowPtr := flags.Duration("window", 72*time.Hour, "How far apart a pledge and its payment may be.")
+	outPtr := flags.String("out", ".", "The directory the reports are written to.")
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *paymentsPtr == "" {
+		fail(fmt.Errorf("-payments is required"))
+	}
+	exportFile := *exportPtr
+	if exportFile == "" {
+		exportFile = path.Join(*statePtr, update.BaseFileName)
+	}
+
+	cleanData, err := data.Clean(exportFile)
+	if err != nil {
+		fail(err)
+	}
+	pledges := data.NewPatronList(data.GetPatronData(cleanData)).Patrons()
+	payments, err := reconcile.LoadPayments(*paymentsPtr)
+	if err != nil {
+		fail(err)
+	}
+	report := reconcile.Reconcile(pledges, payments, *windowPtr)
+
+	if err := os.MkdirAll(*outPtr, os.ModeDir|os.ModePerm); err != nil {
+		fail(err)
+	}
+	outputs := map[string]func(*os.File) error{
+		"reconcile.csv":          func(f *os.File) error { return report.WriteCSV(f) },
+		"reconcile.html":         func(f *os.File) error { return report.WriteHTML(f) },
+		"collected_payments.csv": func(f *os.File) error { return report.Reconciliation(f) },
+	}
+	for name, write := range outputs {
+		file, err := os.Create(path.Join(*outPtr, name))
+		if err != nil {
+			fail(err)
+		}
+		err = write(file)
+		file.Close()
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	pledged, received := report.Totals()
+	fmt.Printf("%d matched, %d mismatched, %d unmatched pledge(s), %d unmatched payment(s).\n",
+		report.Count(reconcile.Matched), report.Count(reconcile.Mismatched),
+		report.Count(reconcile.UnmatchedPledge), report.Count(reconcile.UnmatchedPayment))
+	fmt.Printf("Pledged $%.2f, received $%.2f. Reports written to %s.\n", pledged, received, *outPtr)
+	fmt.Println("Pass collected_payments.csv to -payments to mark the matched pledges as collected.")
+}
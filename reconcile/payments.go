@@ -0,0 +1,159 @@
+// Package reconcile matches the pledges in the campaign export against the
+// money that was actually received, as shown in a payment processor or bank
+// export, so finance can check the published totals.
+package reconcile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payment is a single transaction from a payment export. Amounts are kept in
+// cents so that processor fees and refunds don't suffer rounding errors.
+type Payment struct {
+	Line   int
+	Time   time.Time
+	Name   string
+	Amount int
+}
+
+var (
+	timeHeaders   = []string{"created (utc)", "created", "date", "transaction date", "posted date", "posting date"}
+	clockHeaders  = []string{"time"}
+	amountHeaders = []string{"amount", "gross", "credit", "deposit"}
+	nameHeaders   = []string{"name", "customer name", "card name", "payer", "from", "description", "memo"}
+
+	dateLayouts = []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		"01/02/2006 15:04:05",
+		"1/2/2006 15:04:05",
+		"01/02/2006",
+		"1/2/2006",
+	}
+)
+
+// LoadPayments reads a CSV export from Stripe, PayPal or a bank. Columns are
+// found by their headings, and rows without a positive amount, such as
+// refunds, fees and withdrawals, are skipped.
+func LoadPayments(fileName string) ([]Payment, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.Trim(name, " \ufeff\""))] = i
+	}
+	timeIdx := findColumn(cols, timeHeaders)
+	clockIdx := findColumn(cols, clockHeaders)
+	amountIdx := findColumn(cols, amountHeaders)
+	nameIdx := findColumn(cols, nameHeaders)
+	if timeIdx < 0 || amountIdx < 0 {
+		return nil, fmt.Errorf("%s: a date and an amount column are required", fileName)
+	}
+
+	var payments []Payment
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+
+		amount, err := parseAmount(column(values, amountIdx))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		stamp := column(values, timeIdx)
+		if clock := column(values, clockIdx); clock != "" {
+			stamp += " " + clock
+		}
+		paid, err := parseTime(stamp)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+
+		payments = append(payments, Payment{
+			Line:   line,
+			Time:   paid,
+			Name:   column(values, nameIdx),
+			Amount: amount,
+		})
+	}
+
+	return payments, nil
+}
+
+// findColumn returns the index of the first heading in names that exists, or
+// -1 if there isn't one.
+func findColumn(cols map[string]int, names []string) int {
+	for _, name := range names {
+		if idx, ok := cols[name]; ok {
+			return idx
+		}
+	}
+	return -1
+}
+
+// column returns the trimmed value at idx, or "" if the row doesn't have it.
+func column(values []string, idx int) string {
+	if idx < 0 || idx >= len(values) {
+		return ""
+	}
+	return strings.TrimSpace(values[idx])
+}
+
+// parseAmount converts an amount such as "$1,234.50" or "(20.00)" into cents.
+func parseAmount(value string) (int, error) {
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	value = strings.Trim(value, "()")
+	value = strings.NewReplacer("$", "", ",", "", " ", "").Replace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	dollars, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		dollars = -dollars
+	}
+	return int(math.Round(dollars * 100)), nil
+}
+
+// parseTime tries each of the date formats used by the supported exports.
+// Times without a zone are taken as UTC.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
@@ -0,0 +1,271 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+const (
+	// Matched means a pledge was paid in full by a payment.
+	Matched string = "matched"
+	// Mismatched means a pledge was paid by a payment from the same donor
+	// around the same time, but for a different amount.
+	Mismatched string = "mismatched"
+	// UnmatchedPledge means no payment could be found for a pledge.
+	UnmatchedPledge string = "unmatched pledge"
+	// UnmatchedPayment means no pledge could be found for a payment.
+	UnmatchedPayment string = "unmatched payment"
+)
+
+var nonLetters = regexp.MustCompile(`[^a-z]+`)
+
+// Line is one row of the reconciliation Report. Pledge or Payment is nil for
+// the unmatched rows.
+type Line struct {
+	Result  string
+	Pledge  *data.Patron
+	Payment *Payment
+}
+
+// Report is the result of reconciling the pledges against the payments.
+type Report struct {
+	Lines []Line
+}
+
+// Reconcile matches each pledge to a payment for the same amount within window
+// of the pledge time whose name agrees with the donor's. Payments without a
+// name, such as bank deposits, match on amount and time alone. A pledge that
+// can only be matched by name and time is reported as mismatched. The closest
+// payment in time wins whenever there is more than one candidate.
+func Reconcile(pledges []*data.Patron, payments []Payment, window time.Duration) *Report {
+	report := &Report{}
+	used := make([]bool, len(payments))
+
+	pass := func(pledges []*data.Patron, sameAmount bool, result string) []*data.Patron {
+		var left []*data.Patron
+		for _, pledge := range pledges {
+			best := -1
+			var bestGap time.Duration
+			for i := range payments {
+				payment := &payments[i]
+				if used[i] || !namesMatch(pledge.SourceName(), payment.Name) {
+					continue
+				}
+				if sameAmount != (payment.Amount == pledge.PledgeAmt()*100) {
+					continue
+				}
+				if !sameAmount && payment.Name == "" {
+					continue
+				}
+				gap := absDuration(payment.Time.Sub(pledge.PledgeTime()))
+				if gap > window {
+					continue
+				}
+				if best < 0 || gap < bestGap {
+					best, bestGap = i, gap
+				}
+			}
+			if best < 0 {
+				left = append(left, pledge)
+				continue
+			}
+			used[best] = true
+			report.Lines = append(report.Lines, Line{Result: result, Pledge: pledge, Payment: &payments[best]})
+		}
+		return left
+	}
+	unpaid := pass(pledges, true, Matched)
+	unpaid = pass(unpaid, false, Mismatched)
+
+	for _, pledge := range unpaid {
+		report.Lines = append(report.Lines, Line{Result: UnmatchedPledge, Pledge: pledge})
+	}
+	for i := range payments {
+		if !used[i] {
+			report.Lines = append(report.Lines, Line{Result: UnmatchedPayment, Payment: &payments[i]})
+		}
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return report.Lines[i].time().Before(report.Lines[j].time())
+	})
+	return report
+}
+
+// namesMatch reports whether every part of the donor's name shows up in the
+// payment's name. A payment without a name matches anyone, but a mismatched
+// result always needs a name to go on.
+func namesMatch(donor, payer string) bool {
+	payerWords := make(map[string]bool)
+	for _, word := range nonLetters.Split(strings.ToLower(payer), -1) {
+		if word != "" {
+			payerWords[word] = true
+		}
+	}
+	if len(payerWords) == 0 {
+		return true
+	}
+
+	for _, word := range nonLetters.Split(strings.ToLower(donor), -1) {
+		if word != "" && !payerWords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns how many lines of the Report have the given result.
+func (report *Report) Count(result string) int {
+	count := 0
+	for _, line := range report.Lines {
+		if line.Result == result {
+			count++
+		}
+	}
+	return count
+}
+
+// Totals returns the dollars pledged and the dollars received, as found in
+// the pledges and payments that were reconciled.
+func (report *Report) Totals() (pledged, received float64) {
+	for _, line := range report.Lines {
+		if line.Pledge != nil {
+			pledged += float64(line.Pledge.PledgeAmt())
+		}
+		if line.Payment != nil {
+			received += float64(line.Payment.Amount) / 100
+		}
+	}
+	return pledged, received
+}
+
+// WriteCSV writes the Report as CSV, one row per line.
+func (report *Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"result", "pledge_name", "pledge_time", "pledge_amount", "payment_line", "payment_name", "payment_time", "payment_amount", "difference"})
+	for _, line := range report.Lines {
+		out.Write(line.fields())
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteHTML writes the Report as a standalone HTML page with a summary at the
+// top.
+func (report *Report) WriteHTML(w io.Writer) error {
+	pledged, received := report.Totals()
+	type row struct {
+		Class  string
+		Fields []string
+	}
+	var rows []row
+	for _, line := range report.Lines {
+		class := strings.Fields(line.Result)[0]
+		rows = append(rows, row{Class: class, Fields: line.fields()})
+	}
+
+	return htmlReport.Execute(w, map[string]interface{}{
+		"Generated":  time.Now().Format("2006-01-02 15:04"),
+		"Matched":    report.Count(Matched),
+		"Mismatched": report.Count(Mismatched),
+		"Pledges":    report.Count(UnmatchedPledge),
+		"Payments":   report.Count(UnmatchedPayment),
+		"Pledged":    fmt.Sprintf("%.2f", pledged),
+		"Received":   fmt.Sprintf("%.2f", received),
+		"Rows":       rows,
+	})
+}
+
+// Reconciliation writes the matched pledges in the format read by
+// data.LoadReconciliation, so the collected payments can be fed back into the
+// published data.
+func (report *Report) Reconciliation(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"name", "pledge_time", "amount", "payment"})
+	for _, line := range report.Lines {
+		if line.Result != Matched {
+			continue
+		}
+		out.Write([]string{
+			line.Pledge.SourceName(),
+			line.Pledge.PledgeTime().Format("2006-01-02 15:04:05"),
+			fmt.Sprint(line.Pledge.PledgeAmt()),
+			data.PaymentCollected,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// fields formats the line for the CSV and HTML reports.
+func (line Line) fields() []string {
+	fields := []string{line.Result, "", "", "", "", "", "", "", ""}
+	if line.Pledge != nil {
+		fields[1] = line.Pledge.SourceName()
+		fields[2] = line.Pledge.PledgeTime().Format("2006-01-02 15:04:05")
+		fields[3] = fmt.Sprintf("%d.00", line.Pledge.PledgeAmt())
+	}
+	if line.Payment != nil {
+		fields[4] = fmt.Sprint(line.Payment.Line)
+		fields[5] = line.Payment.Name
+		fields[6] = line.Payment.Time.Format("2006-01-02 15:04:05")
+		fields[7] = fmt.Sprintf("%.2f", float64(line.Payment.Amount)/100)
+	}
+	if line.Pledge != nil && line.Payment != nil {
+		fields[8] = fmt.Sprintf("%.2f", float64(line.Payment.Amount-line.Pledge.PledgeAmt()*100)/100)
+	}
+	return fields
+}
+
+// time is when the line happened, used to sort the Report.
+func (line Line) time() time.Time {
+	if line.Pledge != nil {
+		return line.Pledge.PledgeTime()
+	}
+	return line.Payment.Time
+}
+
+func absDuration(d time.Duration) time.Duration {
+	return time.Duration(math.Abs(float64(d)))
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Donation reconciliation</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+tr.matched { background: #e8f5e9; }
+tr.mismatched { background: #fff8e1; }
+tr.unmatched { background: #ffebee; }
+</style>
+</head>
+<body>
+<h1>Donation reconciliation</h1>
+<p>Generated {{.Generated}}</p>
+<ul>
+<li>Matched: {{.Matched}}</li>
+<li>Mismatched: {{.Mismatched}}</li>
+<li>Unmatched pledges: {{.Pledges}}</li>
+<li>Unmatched payments: {{.Payments}}</li>
+<li>Pledged: ${{.Pledged}}, received: ${{.Received}}</li>
+</ul>
+<table>
+<tr><th>Result</th><th>Donor</th><th>Pledge time</th><th>Pledged</th><th>Payment line</th><th>Payer</th><th>Payment time</th><th>Received</th><th>Difference</th></tr>
+{{range .Rows}}<tr class="{{.Class}}">{{range .Fields}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
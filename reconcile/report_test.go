@@ -0,0 +1,64 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconcile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paymentsFile := path.Join(dir, "stripe.csv")
+	ioutil.WriteFile(paymentsFile, []byte(
+		"id,Created (UTC),Amount,Card Name\n"+
+			"ch_1,2019-03-31 13:25:00,50.00,JANE A SMITH\n"+
+			"ch_2,2019-04-01 15:01:00,\"$95.00\",John Doe\n"+
+			"ch_3,2019-04-05 09:00:00,25.00,Someone Else\n"+
+			"re_1,2019-04-06 09:00:00,-50.00,Jane A Smith\n"), 0644)
+
+	pledges := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 50),
+		data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 100),
+		data.NewPatron(3, "2019-04-02 12:30:00", false, "Sam", "Jones", 50),
+	}
+	payments, err := reconcile.LoadPayments(paymentsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 3 {
+		t.Fatal("For", "LoadPayments()", "expected", 3, "got", len(payments))
+	}
+
+	report := reconcile.Reconcile(pledges, payments, 72*time.Hour)
+	counts := map[string]int{
+		reconcile.Matched:          1,
+		reconcile.Mismatched:       1,
+		reconcile.UnmatchedPledge:  1,
+		reconcile.UnmatchedPayment: 1,
+	}
+	for result, expected := range counts {
+		if got := report.Count(result); got != expected {
+			t.Error("For", result, "expected", expected, "got", got)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.Reconciliation(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Jane Smith,2019-03-31 08:21:16,50,collected") {
+		t.Error("For", "Reconciliation()", "expected", "Jane Smith collected", "got", out.String())
+	}
+}
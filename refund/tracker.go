@@ -0,0 +1,385 @@
+// Package refund keeps track of pledges between runs so that refunds and
+// cancellations are handled by an explicit policy instead of silently
+// reshuffling every other donor's cells.
+package refund
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// Policy decides what happens to the cells of a refunded or cancelled pledge.
+type Policy string
+
+const (
+	// PolicyRelease removes the pledge so its cells go back into the pool.
+	PolicyRelease Policy = "release"
+	// PolicyKeep leaves the cells with the donor as a courtesy.
+	PolicyKeep Policy = "keep"
+	// PolicyReview keeps the cells in place until an operator decides.
+	PolicyReview Policy = "review"
+
+	// SnapshotFile holds every pledge seen on previous runs. It holds the
+	// real names of anonymous donors, so it's only readable by its owner.
+	SnapshotFile string = "pledges.json"
+	// DecisionsFile holds the operator's decisions for pledges under review.
+	DecisionsFile string = "refund_decisions.json"
+
+	timeFormat string = "2006-01-02 15:04:05"
+)
+
+// ParsePolicy converts a policy name into a Policy.
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(name)); policy {
+	case PolicyRelease, PolicyKeep, PolicyReview:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown refund policy %q", name)
+}
+
+// Record is what the Tracker remembers about a single pledge. It holds enough
+// to rebuild the pledge if it disappears from the export.
+type Record struct {
+	Key        string    `json:"key"`
+	Name       string    `json:"name"`
+	Anonymous  bool      `json:"anonymous"`
+	PledgeTime string    `json:"pledge_time"`
+	Amount     int       `json:"amount"`
+	Status     string    `json:"status"`
+	Outcome    Policy    `json:"outcome,omitempty"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+
+	// Payment is the collection status of the pledge, and FailedSince is
+	// when its payment was first seen to fail.
+	Payment     string     `json:"payment,omitempty"`
+	FailedSince *time.Time `json:"failed_since,omitempty"`
+
+	Message   string            `json:"message,omitempty"`
+	Placement string            `json:"placement,omitempty"`
+	Channel   string            `json:"channel,omitempty"`
+	Tracked   bool              `json:"tracked,omitempty"`
+	Gift      *GiftRecord       `json:"gift,omitempty"`
+	Contact   map[string]string `json:"contact,omitempty"`
+}
+
+// GiftRecord is what the Tracker remembers about a gift adoption.
+type GiftRecord struct {
+	Honoree   string `json:"honoree"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+	HideGiver bool   `json:"hide_giver,omitempty"`
+}
+
+// Tracker compares each run's pledges against the previous snapshot and
+// applies the refund policy to anything that was refunded or has disappeared.
+type Tracker struct {
+	stateDir  string
+	policy    Policy
+	records   map[string]*Record
+	decisions map[string]Policy
+	grace     time.Duration
+	audit     *audit.Log
+}
+
+// Load reads the snapshot and operator decisions stored in stateDir.
+func Load(stateDir string, policy Policy) (*Tracker, error) {
+	tracker := &Tracker{
+		stateDir:  stateDir,
+		policy:    policy,
+		records:   make(map[string]*Record),
+		decisions: make(map[string]Policy),
+		audit:     audit.Open(path.Join(stateDir, audit.FileName)),
+	}
+
+	var records []*Record
+	if err := readJSON(path.Join(stateDir, SnapshotFile), &records); err != nil {
+		return nil, err
+	}
+	if err := readJSON(path.Join(stateDir, DecisionsFile), &tracker.decisions); err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		tracker.records[record.Key] = record
+	}
+
+	return tracker, nil
+}
+
+// Preview stops the Tracker from writing to the audit log, so the refund
+// policy can be applied for a report without recording anything. A Tracker
+// being previewed must not be saved.
+func (tracker *Tracker) Preview() {
+	tracker.audit = audit.Open("")
+}
+
+// SetGracePeriod sets how long the cells of a pledge with a failed payment are
+// held as pending before they're released.
+func (tracker *Tracker) SetGracePeriod(grace time.Duration) {
+	tracker.grace = grace
+}
+
+// Key identifies a single pledge across runs. It's a hash of the donor, time
+// and amount so that the snapshot and audit log don't reveal who an anonymous
+// donor is.
+func Key(patron *data.Patron) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", patron.Key(), patron.PledgeAmt())))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Apply updates the snapshot with the current pledges and returns the patrons
+// that should be allocated cells. Refunded, cancelled and disappeared pledges
+// are dropped or kept according to the policy, pledges whose payment has
+// failed for longer than the grace period are dropped, and every change is
+// written to the audit log.
+func (tracker *Tracker) Apply(patrons []*data.Patron) ([]*data.Patron, error) {
+	now := time.Now()
+	seen := make(map[string]bool)
+	var kept []*data.Patron
+
+	for _, patron := range patrons {
+		key := Key(patron)
+		seen[key] = true
+
+		record, known := tracker.records[key]
+		if !known {
+			record = &Record{
+				Key:        key,
+				Name:       patron.SourceName(),
+				PledgeTime: patron.PledgeTime().Format(timeFormat),
+				Amount:     patron.PledgeAmt(),
+				Status:     data.PledgeActive,
+				FirstSeen:  now,
+			}
+			tracker.records[key] = record
+		}
+		record.LastSeen = now
+		record.remember(patron)
+
+		if err := tracker.update(record, patron.Status()); err != nil {
+			return nil, err
+		}
+		lapsed, err := tracker.payment(record, patron.Payment(), now)
+		if err != nil {
+			return nil, err
+		}
+		if record.Outcome != PolicyRelease && !lapsed {
+			kept = append(kept, patron)
+		}
+	}
+
+	// Anything from the last snapshot that is missing now was cancelled
+	// without the export saying so.
+	nextID := len(patrons) + 1
+	for _, record := range tracker.Records() {
+		if seen[record.Key] {
+			continue
+		}
+		status := record.Status
+		if status == data.PledgeActive || status == data.PledgePending {
+			status = data.PledgeCancelled
+		}
+		if err := tracker.update(record, status); err != nil {
+			return nil, err
+		}
+		if record.Outcome == PolicyRelease {
+			continue
+		}
+
+		// The pledge has to be rebuilt from the snapshot to keep its cells.
+		kept = append(kept, record.patron(nextID))
+		nextID++
+	}
+
+	return kept, nil
+}
+
+// remember copies the details of the pledge that aren't part of its key into
+// the Record.
+func (record *Record) remember(patron *data.Patron) {
+	record.Anonymous = patron.Anonymous()
+	record.Message = patron.Message()
+	record.Placement = patron.Placement()
+	record.Contact = patron.Contacts()
+	record.Channel = ""
+	record.Tracked = false
+	if pledges := patron.Pledges(); len(pledges) > 0 {
+		record.Channel = pledges[0].Channel
+		record.Tracked = pledges[0].Tracked
+	}
+	record.Gift = nil
+	if gift := patron.Gift(); gift != nil {
+		record.Gift = &GiftRecord{Honoree: gift.Honoree(), Anonymous: gift.Anonymous(), HideGiver: gift.HideGiver()}
+	}
+}
+
+// patron rebuilds the pledge from the Record with the given id.
+func (record *Record) patron(id int) *data.Patron {
+	names := strings.SplitN(record.Name, " ", 2)
+	names = append(names, "")
+	patron := data.NewPatron(id, record.PledgeTime, record.Anonymous, names[0], names[1], record.Amount)
+	patron.SetStatus(record.Status)
+	patron.SetPayment(record.Payment)
+	patron.SetPlacement(record.Placement)
+	patron.SetChannel(record.Channel, record.Tracked)
+	if record.Message != "" {
+		patron.SetMessage(record.Message)
+	}
+	if record.Gift != nil {
+		patron.SetGift(data.NewGift(record.Gift.Honoree, record.Gift.Anonymous, record.Gift.HideGiver))
+	}
+	for attr, value := range record.Contact {
+		patron.SetContact(attr, value)
+	}
+	return patron
+}
+
+// update moves record to status and works out its outcome, logging anything
+// that changed.
+func (tracker *Tracker) update(record *Record, status string) error {
+	if status != record.Status {
+		detail := fmt.Sprintf("%s -> %s", record.Status, status)
+		if err := tracker.audit.Record("status", record.Key, detail); err != nil {
+			return err
+		}
+		record.Status = status
+	}
+
+	var outcome Policy
+	if status == data.PledgeRefunded || status == data.PledgeCancelled {
+		outcome = tracker.policy
+		if decision, ok := tracker.decisions[record.Key]; ok {
+			outcome = decision
+		}
+	}
+
+	if outcome != record.Outcome {
+		detail := string(outcome)
+		if detail == "" {
+			detail = "reinstated"
+		}
+		if err := tracker.audit.Record("refund-policy", record.Key, detail); err != nil {
+			return err
+		}
+		record.Outcome = outcome
+	}
+
+	return nil
+}
+
+// payment keeps track of how long the pledge's payment has been failing, and
+// reports whether the grace period has run out.
+func (tracker *Tracker) payment(record *Record, payment string, now time.Time) (bool, error) {
+	record.Payment = payment
+	if payment != data.PaymentFailed {
+		if record.FailedSince != nil {
+			record.FailedSince = nil
+			if err := tracker.audit.Record("payment", record.Key, "recovered"); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	if record.FailedSince == nil {
+		record.FailedSince = &now
+		detail := fmt.Sprintf("failed, released after %s", now.Add(tracker.grace).Format(timeFormat))
+		if err := tracker.audit.Record("payment", record.Key, detail); err != nil {
+			return false, err
+		}
+	}
+	return now.Sub(*record.FailedSince) >= tracker.grace, nil
+}
+
+// Records returns every pledge in the snapshot, oldest first.
+func (tracker *Tracker) Records() []*Record {
+	records := make([]*Record, 0, len(tracker.records))
+	for _, record := range tracker.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].PledgeTime == records[j].PledgeTime {
+			return records[i].Key < records[j].Key
+		}
+		return records[i].PledgeTime < records[j].PledgeTime
+	})
+	return records
+}
+
+// UnderReview returns the pledges that are waiting for an operator decision.
+func (tracker *Tracker) UnderReview() []*Record {
+	var review []*Record
+	for _, record := range tracker.Records() {
+		if record.Outcome == PolicyReview {
+			review = append(review, record)
+		}
+	}
+	return review
+}
+
+// Decide records the operator's decision for the pledge with the given key. The
+// decision is logged and takes effect on the next run.
+func (tracker *Tracker) Decide(key string, decision Policy) error {
+	if _, ok := tracker.records[key]; !ok {
+		return fmt.Errorf("no pledge with key %q", key)
+	}
+	if decision != PolicyRelease && decision != PolicyKeep {
+		return fmt.Errorf("a refund can only be released or kept, not %q", decision)
+	}
+
+	tracker.decisions[key] = decision
+	if err := tracker.audit.Record("refund-decision", key, string(decision)); err != nil {
+		return err
+	}
+	return writeJSON(path.Join(tracker.stateDir, DecisionsFile), tracker.decisions)
+}
+
+// Save writes the snapshot back to the state directory.
+func (tracker *Tracker) Save() error {
+	return writeJSON(path.Join(tracker.stateDir, SnapshotFile), tracker.Records())
+}
+
+// readJSON unmarshals the file at fileName into v. A missing file leaves v
+// untouched.
+func readJSON(fileName string, v interface{}) error {
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	return nil
+}
+
+// writeJSON marshals v into the file at fileName. The files hold donors' real
+// names, so only their owner can read them.
+func writeJSON(fileName string, v interface{}) error {
+	err := os.MkdirAll(path.Dir(fileName), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fileName, content, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(fileName, 0600)
+}
@@ -0,0 +1,200 @@
+package refund_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/refund"
+)
+
+// pledges returns a fresh copy of the export used by the tests. Jane's pledge
+// is anonymous and is a gift with a dedication.
+func pledges() []*data.Patron {
+	jane := data.NewPatron(1, "2019-03-31 08:21:16", true, "Jane", "Smith", 100)
+	jane.SetGift(data.NewGift("Sam Smith", false, true))
+	jane.SetMessage("Happy birthday")
+	jane.SetContact("email", "jane@example.com")
+	john := data.NewPatron(2, "2019-04-01 10:00:00", false, "John", "Doe", 50)
+	return []*data.Patron{jane, john}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	tests := []struct {
+		policy refund.Policy
+		kept   int
+		review int
+	}{
+		{refund.PolicyRelease, 1, 0},
+		{refund.PolicyKeep, 2, 0},
+		{refund.PolicyReview, 2, 1},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "refund")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		tracker, err := refund.Load(dir, test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patrons := pledges()
+		patrons[1].SetStatus(data.PledgeRefunded)
+		kept, err := tracker.Apply(patrons)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kept) != test.kept || len(tracker.UnderReview()) != test.review {
+			t.Error(
+				"For", test.policy,
+				"expected", test.kept, "kept", test.review, "under review",
+				"got", len(kept), len(tracker.UnderReview()),
+			)
+		}
+	}
+}
+
+func TestDecisionOverridesPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tracker, _ := refund.Load(dir, refund.PolicyReview)
+	patrons := pledges()
+	patrons[1].SetStatus(data.PledgeCancelled)
+	tracker.Apply(patrons)
+	if err := tracker.Decide(refund.Key(patrons[1]), refund.PolicyRelease); err != nil {
+		t.Fatal(err)
+	}
+
+	patrons = pledges()
+	patrons[1].SetStatus(data.PledgeCancelled)
+	kept, err := tracker.Apply(patrons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 || len(tracker.UnderReview()) != 0 {
+		t.Error("For", "a released pledge", "expected", "1 kept / 0 under review", "got", len(kept), len(tracker.UnderReview()))
+	}
+}
+
+func TestDisappearedPledges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tracker, _ := refund.Load(dir, refund.PolicyKeep)
+	if _, err := tracker.Apply(pledges()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jane's pledge is missing from the next export.
+	tracker, err = refund.Load(dir, refund.PolicyKeep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := tracker.Apply(pledges()[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 {
+		t.Fatal("For", "kept pledges", "expected", 2, "got", len(kept))
+	}
+	jane := kept[1]
+	if jane.Status() != data.PledgeCancelled || !jane.Anonymous() || jane.Message() != "Happy birthday" {
+		t.Error(
+			"For", "rebuilt pledge",
+			"expected", "cancelled / anonymous / Happy birthday",
+			"got", jane.Status(), jane.Anonymous(), jane.Message(),
+		)
+	}
+	if jane.Gift() == nil || jane.Gift().Honoree() != "Sam Smith" || !jane.Gift().HideGiver() {
+		t.Error("For", "rebuilt gift", "expected", "Sam Smith, giver hidden", "got", jane.Gift())
+	}
+	if email := jane.Contact("email"); email != "jane@example.com" {
+		t.Error("For", "rebuilt contact", "expected", "jane@example.com", "got", email)
+	}
+
+	// When it reappears, it's reinstated.
+	kept, err = tracker.Apply(pledges())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range tracker.Records() {
+		if record.Status != data.PledgeActive || record.Outcome != "" {
+			t.Error("For", record.Key, "expected", "active", "got", record.Status, record.Outcome)
+		}
+	}
+	if len(kept) != 2 {
+		t.Error("For", "reappeared pledge", "expected", 2, "got", len(kept))
+	}
+}
+
+func TestSnapshotHidesDonors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tracker, _ := refund.Load(dir, refund.PolicyKeep)
+	patrons := pledges()
+	patrons[0].SetStatus(data.PledgeRefunded)
+	if _, err := tracker.Apply(patrons); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileName := range []string{refund.SnapshotFile, audit.FileName} {
+		info, err := os.Stat(path.Join(dir, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Error("For", fileName, "expected", os.FileMode(0600), "got", mode)
+		}
+	}
+
+	content, _ := ioutil.ReadFile(path.Join(dir, audit.FileName))
+	if strings.Contains(strings.ToLower(string(content)), "jane") {
+		t.Error("For", audit.FileName, "expected", "no donor names", "got", string(content))
+	}
+}
+
+func TestPreview(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tracker, _ := refund.Load(dir, refund.PolicyRelease)
+	tracker.Preview()
+	patrons := pledges()
+	patrons[1].SetStatus(data.PledgeRefunded)
+	kept, err := tracker.Apply(patrons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 {
+		t.Error("For", "preview", "expected", 1, "kept", "got", len(kept))
+	}
+	if _, err := os.Stat(path.Join(dir, audit.FileName)); !os.IsNotExist(err) {
+		t.Error("For", audit.FileName, "expected", "no audit log", "got", err)
+	}
+}
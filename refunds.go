@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/refund"
+)
+
+// runRefunds implements the "refunds" subcommand, which lets an operator decide
+// what happens to the cells of pledges held for review.
+func runRefunds(args []string) {
+	flags := flag.NewFlagSet("refunds", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the pledge snapshot.")
+	keepPtr := flags.String("keep", "", "A pledge key, or its #number from the listing, whose cells stay with the donor.")
+	releasePtr := flags.String("release", "", "A pledge key, or its #number from the listing, whose cells are released.")
+	allPtr := flags.Bool("all", false, "List every refunded or cancelled pledge instead of only those under review.")
+	flags.Parse(args)
+
+	tracker, err := refund.Load(*statePtr, refund.PolicyReview)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *keepPtr != "" || *releasePtr != "" {
+		if *keepPtr != "" {
+			err = tracker.Decide(recordKey(tracker, *keepPtr), refund.PolicyKeep)
+		}
+		if *releasePtr != "" && err == nil {
+			err = tracker.Decide(recordKey(tracker, *releasePtr), refund.PolicyRelease)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	listed := 0
+	for i, record := range tracker.Records() {
+		if record.Outcome == "" || (!*allPtr && record.Outcome != refund.PolicyReview) {
+			continue
+		}
+		fmt.Printf("#%-3d %-9s %-7s %-30s $%-6d %s\n", i+1, record.Status, record.Outcome, record.Name, record.Amount, record.PledgeTime)
+		listed++
+	}
+	if listed == 0 {
+		fmt.Println("Nothing waiting for review.")
+	}
+}
+
+// recordKey resolves a "#N" reference from the listing into a pledge key.
+// Anything else is returned as-is.
+func recordKey(tracker *refund.Tracker, ref string) string {
+	if !strings.HasPrefix(ref, "#") {
+		return ref
+	}
+
+	idx, err := strconv.Atoi(ref[1:])
+	records := tracker.Records()
+	if err != nil || idx < 1 || idx > len(records) {
+		return ref
+	}
+	return records[idx-1].Key
+}
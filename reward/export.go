@@ -0,0 +1,155 @@
+package reward
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// columns returns the headings of the exported list. Shipping fields are only
+// included if at least one record has them, since not every export does.
+func columns(records []*Record) []string {
+	header := []string{"key", "name", "tier", "reward", "amount"}
+	for _, field := range ShippingFields {
+		for _, record := range records {
+			if record.Contact[field] != "" {
+				header = append(header, field)
+				break
+			}
+		}
+	}
+	return append(header, "status", "updated")
+}
+
+// row returns the values of record under the given headings. Numbers are
+// returned as numbers so a spreadsheet can sum them.
+func row(record *Record, header []string) []interface{} {
+	values := make([]interface{}, 0, len(header))
+	for _, name := range header {
+		switch name {
+		case "key":
+			values = append(values, record.Key)
+		case "name":
+			values = append(values, record.Name)
+		case "tier":
+			values = append(values, record.Tier)
+		case "reward":
+			values = append(values, record.Reward)
+		case "amount":
+			values = append(values, record.Amount)
+		case "status":
+			values = append(values, record.Status)
+		case "updated":
+			values = append(values, record.Updated.Format(time.RFC3339))
+		default:
+			values = append(values, record.Contact[name])
+		}
+	}
+	return values
+}
+
+// WriteCSV writes the records as a CSV fulfillment list.
+func WriteCSV(w io.Writer, records []*Record) error {
+	writer := csv.NewWriter(w)
+	header := columns(records)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, record := range records {
+		var line []string
+		for _, value := range row(record, header) {
+			switch value := value.(type) {
+			case int:
+				line = append(line, strconv.Itoa(value))
+			default:
+				line = append(line, value.(string))
+			}
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// xlsxParts are the fixed parts of a workbook with a single sheet.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Rewards" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// WriteXLSX writes the records as an Excel workbook with a single sheet.
+func WriteXLSX(w io.Writer, records []*Record) error {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+
+	header := columns(records)
+	rows := [][]interface{}{}
+	headerRow := make([]interface{}, len(header))
+	for i, name := range header {
+		headerRow[i] = name
+	}
+	rows = append(rows, headerRow)
+	for _, record := range records {
+		rows = append(rows, row(record, header))
+	}
+
+	sheet := bytes.NewBufferString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for _, values := range rows {
+		sheet.WriteString("<row>")
+		for _, value := range values {
+			switch value := value.(type) {
+			case int:
+				sheet.WriteString("<c><v>" + strconv.Itoa(value) + "</v></c>")
+			default:
+				sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+				if err := xml.EscapeText(sheet, []byte(value.(string))); err != nil {
+					return err
+				}
+				sheet.WriteString("</t></is></c>")
+			}
+		}
+		sheet.WriteString("</row>")
+	}
+	sheet.WriteString("</sheetData></worksheet>")
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := sheet.WriteTo(file); err != nil {
+		return err
+	}
+	return archive.Close()
+}
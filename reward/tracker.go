@@ -0,0 +1,278 @@
+// Package reward keeps the list of donors owed a physical reward for their
+// recognition tier, and how far along each reward is in being sent out.
+package reward
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/audit"
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+const (
+	// StatusPending marks a reward that hasn't been sent yet.
+	StatusPending string = "pending"
+	// StatusShipped marks a reward that's on its way.
+	StatusShipped string = "shipped"
+	// StatusDelivered marks a reward the donor has received.
+	StatusDelivered string = "delivered"
+	// StatusDeclined marks a reward the donor turned down.
+	StatusDeclined string = "declined"
+
+	// StateFile holds the fulfillment list between runs. It includes the
+	// donors' contact details, so it's only readable by the service user.
+	StateFile string = "fulfillment.json"
+)
+
+// ShippingFields are the contact details copied from the export for sending
+// rewards, in the order they're exported.
+var ShippingFields = []string{"email", "phone", "address", "address2", "city", "state", "zip", "country"}
+
+// ParseStatus converts a fulfillment status name into one of the Status
+// constants.
+func ParseStatus(name string) (string, error) {
+	switch status := strings.ToLower(strings.TrimSpace(name)); status {
+	case StatusPending, StatusShipped, StatusDelivered, StatusDeclined:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown fulfillment status %q", name)
+}
+
+// Record is one reward owed to one donor. Donors are identified by
+// data.Patron.Key, so a donor who moves up a tier gets a new Record for the
+// new reward and keeps the old one, which is no longer eligible.
+type Record struct {
+	Key      string            `json:"key"`
+	Name     string            `json:"name"`
+	Tier     string            `json:"tier"`
+	Reward   string            `json:"reward"`
+	Amount   int               `json:"amount"`
+	Contact  map[string]string `json:"contact,omitempty"`
+	Eligible bool              `json:"eligible"`
+	Status   string            `json:"status"`
+	Updated  time.Time         `json:"updated"`
+}
+
+// id identifies the Record in the Tracker.
+func (record *Record) id() string {
+	return record.Key + "|" + record.Reward
+}
+
+// Tracker holds the fulfillment list in the state directory.
+type Tracker struct {
+	stateDir string
+	records  map[string]*Record
+	audit    *audit.Log
+}
+
+// Load reads the fulfillment list stored in stateDir.
+func Load(stateDir string) (*Tracker, error) {
+	tracker := &Tracker{
+		stateDir: stateDir,
+		records:  make(map[string]*Record),
+		audit:    audit.Open(path.Join(stateDir, audit.FileName)),
+	}
+
+	var records []*Record
+	if err := readJSON(path.Join(stateDir, StateFile), &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		tracker.records[record.id()] = record
+	}
+
+	return tracker, nil
+}
+
+// Sync brings the list up to date with the current patrons. Donors in a tier
+// with a reward are added as pending once their payment has been collected,
+// and the details of existing records are refreshed from the export. Records
+// for donors who've since been refunded or changed tier are kept, but are no
+// longer eligible. Credit carried in from an earlier campaign was rewarded
+// there, so it never earns a reward here.
+func (tracker *Tracker) Sync(patrons []*data.Patron, recognition *data.Recognition) {
+	now := time.Now()
+	eligible := make(map[string]bool)
+
+	for _, patron := range patrons {
+		if patron.Status() != data.PledgeActive || patron.Carried() || patron.Payment() != data.PaymentCollected {
+			continue
+		}
+		tier, ok := recognition.TierFor(patron.PledgeAmt())
+		if !ok || tier.Reward == "" {
+			continue
+		}
+
+		record := &Record{Key: patron.Key(), Reward: tier.Reward}
+		if known, ok := tracker.records[record.id()]; ok {
+			record = known
+		} else {
+			record.Status = StatusPending
+			record.Updated = now
+			tracker.records[record.id()] = record
+		}
+		record.Name = patron.SourceName()
+		record.Tier = tier.Name
+		record.Amount = patron.PledgeAmt()
+		record.Contact = make(map[string]string)
+		for _, field := range ShippingFields {
+			if value := patron.Contact(field); value != "" {
+				record.Contact[field] = value
+			}
+		}
+		eligible[record.id()] = true
+	}
+
+	for id, record := range tracker.records {
+		record.Eligible = eligible[id]
+	}
+}
+
+// Set records a new fulfillment status for the reward owed to the donor with
+// the given key, and writes the change to the audit log.
+func (tracker *Tracker) Set(key, reward, status string) error {
+	record, ok := tracker.records[key+"|"+reward]
+	if !ok {
+		return fmt.Errorf("no %q reward for %s", reward, key)
+	}
+	status, err := ParseStatus(status)
+	if err != nil {
+		return err
+	}
+	if status == record.Status {
+		return nil
+	}
+
+	detail := fmt.Sprintf("%s: %s -> %s", reward, record.Status, status)
+	if err := tracker.audit.Record("reward", key, detail); err != nil {
+		return err
+	}
+	record.Status = status
+	record.Updated = time.Now()
+	return nil
+}
+
+// Records returns every record, sorted by tier amount and then name. If all
+// is false, only eligible records are returned.
+func (tracker *Tracker) Records(all bool) []*Record {
+	records := make([]*Record, 0, len(tracker.records))
+	for _, record := range tracker.records {
+		if all || record.Eligible {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Amount != records[j].Amount {
+			return records[i].Amount > records[j].Amount
+		}
+		return records[i].id() < records[j].id()
+	})
+	return records
+}
+
+// Save writes the fulfillment list back to the state directory.
+func (tracker *Tracker) Save() error {
+	return writeJSON(path.Join(tracker.stateDir, StateFile), tracker.Records(true))
+}
+
+// Update is a status change for one reward, as read from an edited export.
+type Update struct {
+	Key    string
+	Reward string
+	Status string
+}
+
+// LoadUpdates reads the status changes in the CSV file at fileName. It takes
+// the same columns as WriteCSV, so an exported list can be edited and read
+// back in. Only the key, reward and status columns are used.
+func LoadUpdates(fileName string) ([]Update, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	idx := map[string]int{"key": -1, "reward": -1, "status": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := idx[name]; ok {
+			idx[name] = i
+		}
+	}
+	for name, i := range idx {
+		if i < 0 {
+			return nil, fmt.Errorf("%s: missing the %q column", fileName, name)
+		}
+	}
+
+	var updates []Update
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+		value := func(name string) string {
+			if idx[name] < len(row) {
+				return strings.TrimSpace(row[idx[name]])
+			}
+			return ""
+		}
+		if value("key") == "" {
+			continue
+		}
+		status, err := ParseStatus(value("status"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		updates = append(updates, Update{Key: value("key"), Reward: value("reward"), Status: status})
+	}
+	return updates, nil
+}
+
+// readJSON unmarshals the file at fileName into v. A missing file leaves v
+// untouched.
+func readJSON(fileName string, v interface{}) error {
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	return nil
+}
+
+// writeJSON marshals v into the file at fileName. Only the service user can
+// read it.
+func writeJSON(fileName string, v interface{}) error {
+	err := os.MkdirAll(path.Dir(fileName), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, 0600)
+}
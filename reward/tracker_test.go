@@ -0,0 +1,114 @@
+package reward_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/reward"
+)
+
+func TestSyncAndExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	patrons := []*data.Patron{
+		data.NewPatron(1, "2019-03-31 08:21:16", false, "Jane", "Smith", 200),
+		data.NewPatron(2, "2019-04-01 10:00:00", true, "John", "Doe", 60),
+		data.NewPatron(3, "2019-04-02 10:00:00", false, "Ann", "Lee", 20),
+	}
+	for i, city := range []string{"Austin", "Dallas", "Waco"} {
+		patrons[i].SetContact("city", city)
+		patrons[i].SetContact("country", "USA")
+	}
+	recognition := &data.Recognition{Tiers: []data.Tier{
+		{Name: "Silver Sponsor", Min: 150, Reward: "T-shirt"},
+		{Name: "Bronze Sponsor", Min: 50, Reward: "Sticker"},
+	}}
+
+	tracker, err := reward.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Sync(patrons, recognition)
+	records := tracker.Records(false)
+	if len(records) != 2 {
+		t.Fatal("For", "rewards", "expected", 2, "got", len(records))
+	}
+	jane := records[0]
+	if jane.Name != "Jane Smith" || jane.Reward != "T-shirt" || jane.Status != reward.StatusPending || jane.Contact["city"] != "Austin" {
+		t.Error("For", "Jane", "expected", "a pending T-shirt to Austin", "got", jane)
+	}
+
+	if err := tracker.Set(jane.Key, "T-shirt", "Shipped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The status survives the next poll, and a refunded donor is no longer
+	// owed their reward.
+	patrons[1].SetStatus(data.PledgeRefunded)
+	tracker, err = reward.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Sync(patrons, recognition)
+	records = tracker.Records(false)
+	if len(records) != 1 || records[0].Status != reward.StatusShipped {
+		t.Error("For", "after refund", "expected", "Jane's shipped T-shirt only", "got", records)
+	}
+	if all := tracker.Records(true); len(all) != 2 {
+		t.Error("For", "every record", "expected", 2, "got", len(all))
+	}
+
+	// Donors who haven't paid yet and credit carried in from an earlier
+	// campaign aren't owed anything.
+	patrons[1].SetStatus(data.PledgeActive)
+	patrons[1].SetPayment(data.PaymentPledged)
+	carried := data.CarriedPatrons([]data.Carryover{{Name: "Bob Lee", PledgeTime: "2018-05-01 10:00:00", Amount: 200}}, 4)
+	tracker.Sync(append(patrons, carried...), recognition)
+	if all := tracker.Records(true); len(all) != 2 {
+		t.Error("For", "unpaid and carried donors", "expected", 2, "records", "got", len(all))
+	}
+
+	var csvOut bytes.Buffer
+	if err := reward.WriteCSV(&csvOut, records); err != nil {
+		t.Fatal(err)
+	}
+	header := strings.SplitN(csvOut.String(), "\n", 2)[0]
+	if header != "key,name,tier,reward,amount,city,country,status,updated" {
+		t.Error("For", "CSV header", "expected", "the shipping fields in the export", "got", header)
+	}
+
+	updateFile := path.Join(dir, "updates.csv")
+	ioutil.WriteFile(updateFile, csvOut.Bytes(), 0644)
+	updates, err := reward.LoadUpdates(updateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Key != jane.Key || updates[0].Status != reward.StatusShipped {
+		t.Error("For", "LoadUpdates()", "expected", "Jane shipped", "got", updates)
+	}
+
+	var xlsxOut bytes.Buffer
+	if err := reward.WriteXLSX(&xlsxOut, records); err != nil {
+		t.Fatal(err)
+	}
+	workbook, err := zip.NewReader(bytes.NewReader(xlsxOut.Bytes()), int64(xlsxOut.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workbook.File) != 5 {
+		t.Error("For", "workbook parts", "expected", 5, "got", len(workbook.File))
+	}
+}
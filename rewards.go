@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/reward"
+)
+
+// runRewards implements the "rewards" subcommand, which records fulfillment
+// updates and exports the list of donors owed a reward. The list itself is
+// kept up to date by the main loop from the tiers in -recognition.
+func runRewards(args []string) {
+	flags := flag.NewFlagSet("rewards", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the fulfillment list.")
+	updatePtr := flags.String("update", "", "A CSV file with key, reward and status columns, such as an edited export, to record.")
+	outPtr := flags.String("out", "rewards.csv", "The file the fulfillment list is exported to. A .xlsx extension writes an Excel workbook.")
+	allPtr := flags.Bool("all", false, "Also export rewards that are no longer owed, such as those of refunded pledges.")
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	tracker, err := reward.Load(*statePtr)
+	if err != nil {
+		fail(err)
+	}
+
+	if *updatePtr != "" {
+		updates, err := reward.LoadUpdates(*updatePtr)
+		if err != nil {
+			fail(err)
+		}
+		for _, update := range updates {
+			if err := tracker.Set(update.Key, update.Reward, update.Status); err != nil {
+				fail(err)
+			}
+		}
+		if err := tracker.Save(); err != nil {
+			fail(err)
+		}
+		fmt.Printf("Recorded %d update(s).\n", len(updates))
+	}
+
+	records := tracker.Records(*allPtr)
+	if err := os.MkdirAll(path.Dir(*outPtr), os.ModeDir|os.ModePerm); err != nil {
+		fail(err)
+	}
+	// The list holds shipping addresses, so only the operator can read it.
+	file, err := os.OpenFile(*outPtr, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		fail(err)
+	}
+	if strings.EqualFold(path.Ext(*outPtr), ".xlsx") {
+		err = reward.WriteXLSX(file, records)
+	} else {
+		err = reward.WriteCSV(file, records)
+	}
+	file.Close()
+	if err != nil {
+		fail(err)
+	}
+
+	counts := make(map[string]int)
+	for _, record := range records {
+		counts[record.Status]++
+	}
+	fmt.Printf("%d reward(s): %d pending, %d shipped, %d delivered, %d declined. Written to %s.\n",
+		len(records), counts[reward.StatusPending], counts[reward.StatusShipped],
+		counts[reward.StatusDelivered], counts[reward.StatusDeclined], *outPtr)
+}
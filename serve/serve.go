@@ -1,23 +1,112 @@
 package serve
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/iAmSomeone2/aacautoupdate/logging"
 )
 
 const (
 	dataLoc string = "/var/www/cell.bdavidson.dev/html/data/data.json"
+
+	campaignPrefix string = "/campaigns/"
+
+	// statsFile is the name of the statistics file published next to each
+	// data file.
+	statsFile string = "stats.json"
 )
 
+// dataFile is the data file served at /patron-data, with the statistics next
+// to it served at /stats.
+var dataFile = struct {
+	sync.RWMutex
+	name string
+}{name: dataLoc}
+
+// SetDataFile makes the data file at fileName available at /patron-data, and
+// the statistics next to it at /stats.
+func SetDataFile(fileName string) {
+	dataFile.Lock()
+	defer dataFile.Unlock()
+	dataFile.name = fileName
+}
+
+// campaigns maps each campaign slug to the data file served for it.
+var campaigns = struct {
+	sync.RWMutex
+	files map[string]string
+}{files: make(map[string]string)}
+
+// AddCampaign makes the data file at fileName available at
+// /campaigns/{slug}/patron-data, and the statistics next to it at
+// /campaigns/{slug}/stats.
+func AddCampaign(slug, fileName string) {
+	campaigns.Lock()
+	defer campaigns.Unlock()
+	campaigns.files[slug] = fileName
+}
+
 func servePatronData(w http.ResponseWriter, r *http.Request) {
+	dataFile.RLock()
+	defer dataFile.RUnlock()
+	serveFile(w, dataFile.name)
+}
+
+func serveStats(w http.ResponseWriter, r *http.Request) {
+	dataFile.RLock()
+	defer dataFile.RUnlock()
+	serveFile(w, path.Join(path.Dir(dataFile.name), statsFile))
+}
+
+// serveCampaign handles every route under /campaigns/. The bare prefix lists
+// the campaign slugs.
+func serveCampaign(w http.ResponseWriter, r *http.Request) {
+	route := strings.Trim(strings.TrimPrefix(r.URL.Path, campaignPrefix), "/")
+
+	campaigns.RLock()
+	defer campaigns.RUnlock()
+
+	if route == "" {
+		slugs := make([]string, 0, len(campaigns.files))
+		for slug := range campaigns.files {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		data, _ := json.Marshal(slugs)
+		w.Write(data)
+		return
+	}
+
+	parts := strings.Split(route, "/")
+	fileName, ok := campaigns.files[parts[0]]
+	if !ok || len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[1] {
+	case "patron-data":
+		serveFile(w, fileName)
+	case "stats":
+		serveFile(w, path.Join(path.Dir(fileName), statsFile))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveFile writes the raw contents of fileName to the response.
+func serveFile(w http.ResponseWriter, fileName string) {
 	/*
 		Since we just need to send the raw JSON data, we should be able to
 		read in the file, and serve the byte stream.
 	*/
 
-	data, err := ioutil.ReadFile(dataLoc)
+	data, err := ioutil.ReadFile(fileName)
 	if err != nil {
 		logger := logging.NewLogger()
 		logger.Warnf("%v", err)
@@ -30,6 +119,8 @@ func StartServer() {
 	logger := logging.NewLogger()
 	logger.Printf("Data server started on separate thread.\n")
 	http.HandleFunc("/patron-data", servePatronData)
+	http.HandleFunc("/stats", serveStats)
+	http.HandleFunc(campaignPrefix, serveCampaign)
 	// ListenAndServe should be changed to the TLS variant for prod.
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		logger.Warnf("%v", err)
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// Channel is the pledges that came in through one outreach channel, such as a
+// tracked link or an event donors said they heard about the campaign at.
+type Channel struct {
+	Name    string    `json:"name"`
+	Pledges int       `json:"pledges"`
+	Amount  int       `json:"amount"`
+	Cells   float64   `json:"cells"`
+	Share   float64   `json:"share"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	Daily   []Day     `json:"daily"`
+}
+
+// ByChannel groups the pledges by outreach channel, largest amount first.
+// price is the price of a cell in dollars, and Share is each channel's
+// percentage of the total amount. The pledges must be in time order.
+func ByChannel(pledges []data.Pledge, price int) []Channel {
+	byName := make(map[string][]data.Pledge)
+	var names []string
+	var total int
+	for _, pledge := range pledges {
+		name := pledge.Channel
+		if name == "" {
+			name = data.Unattributed
+		}
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], pledge)
+		total += pledge.Amount
+	}
+
+	channels := []Channel{}
+	for _, name := range names {
+		grouped := byName[name]
+		channel := Channel{
+			Name:    name,
+			Pledges: len(grouped),
+			First:   grouped[0].Time,
+			Last:    grouped[len(grouped)-1].Time,
+			Daily:   daily(grouped),
+		}
+		for _, pledge := range grouped {
+			channel.Amount += pledge.Amount
+		}
+		channel.Cells = float64(channel.Amount) / float64(price)
+		if total > 0 {
+			channel.Share = 100 * float64(channel.Amount) / float64(total)
+		}
+		channels = append(channels, channel)
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Amount > channels[j].Amount
+	})
+	return channels
+}
+
+// PublicChannels is ByChannel for the published statistics. Only channels from
+// tracked links are named. Pledges whose channel is what the donor wrote in the
+// referral or survey columns are grouped under data.SelfReported, since those
+// answers can hold anything, including other people's names.
+func PublicChannels(pledges []data.Pledge, price int) []Channel {
+	public := make([]data.Pledge, len(pledges))
+	for i, pledge := range pledges {
+		if !pledge.Tracked && pledge.Channel != "" && pledge.Channel != data.Unattributed {
+			pledge.Channel = data.SelfReported
+		}
+		public[i] = pledge
+	}
+	return ByChannel(public, price)
+}
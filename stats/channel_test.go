@@ -0,0 +1,66 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+)
+
+func TestByChannel(t *testing.T) {
+	at := func(day int) time.Time {
+		return time.Date(2020, 3, day, 12, 0, 0, 0, time.UTC)
+	}
+	pledges := []data.Pledge{
+		{Time: at(1), Amount: 50, Channel: "facebook / social"},
+		{Time: at(1), Amount: 25},
+		{Time: at(2), Amount: 100, Channel: "facebook / social"},
+		{Time: at(3), Amount: 25, Channel: "county fair"},
+	}
+	channels := stats.ByChannel(pledges, 50)
+
+	if len(channels) != 3 {
+		t.Fatal("For", "channels", "expected", 3, "got", len(channels))
+	}
+	facebook := channels[0]
+	if facebook.Name != "facebook / social" || facebook.Amount != 150 || facebook.Cells != 3 || facebook.Share != 75 {
+		t.Error("For", "facebook", "expected", "$150, 3 cells, 75%", "got", facebook)
+	}
+	if len(facebook.Daily) != 2 || facebook.Daily[1].RunningAmount != 150 {
+		t.Error("For", "facebook by day", "expected", "2 days ending at 150", "got", facebook.Daily)
+	}
+	if channels[1].Name != data.Unattributed {
+		t.Error("For", "pledge without a channel", "expected", data.Unattributed, "got", channels[1].Name)
+	}
+}
+
+func TestPublicChannels(t *testing.T) {
+	at := func(day int) time.Time {
+		return time.Date(2020, 3, day, 12, 0, 0, 0, time.UTC)
+	}
+	pledges := []data.Pledge{
+		{Time: at(1), Amount: 50, Channel: "facebook / social", Tracked: true},
+		{Time: at(1), Amount: 25},
+		{Time: at(2), Amount: 25, Channel: "jane smith"},
+		{Time: at(3), Amount: 25, Channel: "county fair"},
+	}
+	names := make(map[string]int)
+	for _, channel := range stats.PublicChannels(pledges, 50) {
+		names[channel.Name] = channel.Pledges
+	}
+	expected := map[string]int{"facebook / social": 1, data.Unattributed: 1, data.SelfReported: 2}
+	if len(names) != len(expected) {
+		t.Error("For", "public channels", "expected", expected, "got", names)
+	}
+	for name, count := range expected {
+		if names[name] != count {
+			t.Error("For", name, "expected", count, "got", names[name])
+		}
+	}
+
+	// The raw answers are still there for the channels report.
+	if channels := stats.ByChannel(pledges, 50); len(channels) != 4 {
+		t.Error("For", "ByChannel()", "expected", 4, "got", len(channels))
+	}
+}
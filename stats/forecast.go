@@ -0,0 +1,354 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// Method is a way of projecting the pledge history forward.
+type Method string
+
+const (
+	// MethodLinear fits a straight line through the running total.
+	MethodLinear Method = "linear"
+	// MethodExponential fits exponential growth to the running total.
+	MethodExponential Method = "exponential"
+	// MethodMovingAverage carries the average daily amount of the last few
+	// days forward.
+	MethodMovingAverage Method = "moving-average"
+
+	// DefaultWindow is the number of days the moving average covers.
+	DefaultWindow int = 7
+
+	// z95 scales a standard error into a 95% confidence bound.
+	z95 float64 = 1.96
+	// horizon is how far ahead a forecast may reach. Anything later is
+	// reported as not expected at all.
+	horizon float64 = 5 * 365
+	day     float64 = float64(24 * time.Hour)
+)
+
+// Bounds is an expected value with its 95% confidence bounds.
+type Bounds struct {
+	Expected float64 `json:"expected"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+}
+
+// Model is one fit of the pledge history. PerDay is in dollars a day, except
+// for the exponential model where it's the growth of the running total as a
+// fraction a day.
+type Model struct {
+	Method Method `json:"method"`
+	PerDay Bounds `json:"per_day"`
+
+	// base is the running total the exponential model grows from.
+	base float64
+}
+
+// days returns how many days it takes to raise amount more dollars at rate,
+// or false if that's beyond the horizon.
+func (model *Model) days(amount, rate float64) (float64, bool) {
+	var days float64
+	switch {
+	case amount <= 0:
+		return 0, true
+	case rate <= 0:
+		return 0, false
+	case model.Method == MethodExponential:
+		days = math.Log1p(amount/model.base) / rate
+	default:
+		days = amount / rate
+	}
+	return days, days <= horizon
+}
+
+// added returns how many more dollars are raised in days at rate.
+func (model *Model) added(days, rate float64) float64 {
+	if model.Method == MethodExponential {
+		return model.base * math.Expm1(rate*days)
+	}
+	return rate * days
+}
+
+// DateEstimate is when one model expects a milestone to be reached. Earliest
+// comes from the high bound of the model and Latest from the low one. A date
+// is left out when it's beyond the forecast horizon.
+type DateEstimate struct {
+	Method   Method     `json:"method"`
+	Expected *time.Time `json:"expected,omitempty"`
+	Earliest *time.Time `json:"earliest,omitempty"`
+	Latest   *time.Time `json:"latest,omitempty"`
+}
+
+// MilestoneForecast is the forecast for one goal milestone.
+type MilestoneForecast struct {
+	data.Milestone
+	Unit     data.GoalUnit  `json:"unit"`
+	Reached  bool           `json:"reached"`
+	Forecast []DateEstimate `json:"forecast,omitempty"`
+}
+
+// CellEstimate is how many cells one model expects to be adopted by the end
+// of the campaign.
+type CellEstimate struct {
+	Method Method `json:"method"`
+	Bounds
+}
+
+// Forecast projects the pledge history forward to the goal milestones and
+// the end of the campaign.
+type Forecast struct {
+	Models     []*Model            `json:"models"`
+	Milestones []MilestoneForecast `json:"milestones,omitempty"`
+	End        *time.Time          `json:"end,omitempty"`
+	CellsAtEnd []CellEstimate      `json:"cells_at_end,omitempty"`
+
+	now   time.Time
+	price int
+}
+
+// NewForecast fits every model that the pledge history has enough data for.
+// The pledges must be in time order. window is the number of days covered by
+// the moving average, and price the price of a cell in dollars.
+func NewForecast(pledges []data.Pledge, now time.Time, window, price int) *Forecast {
+	forecast := &Forecast{Models: []*Model{}, now: now, price: price}
+	if len(pledges) == 0 {
+		return forecast
+	}
+
+	// The running total after each pledge, with the time since the first
+	// pledge in days. The current time is included so a quiet spell pulls
+	// the fits down.
+	first := pledges[0].Time
+	var times, totals []float64
+	var total float64
+	for _, pledge := range pledges {
+		total += float64(pledge.Amount)
+		times = append(times, float64(pledge.Time.Sub(first))/day)
+		totals = append(totals, total)
+	}
+	if now.After(pledges[len(pledges)-1].Time) {
+		times = append(times, float64(now.Sub(first))/day)
+		totals = append(totals, total)
+	}
+
+	if slope, err, ok := fitLine(times, totals); ok {
+		forecast.add(&Model{
+			Method: MethodLinear,
+			PerDay: bounds(slope, err),
+		})
+	}
+	// The exponential fit can only use the points after the running total
+	// leaves 0, since $0 pledges may come first.
+	var logTimes, logs []float64
+	for i, t := range totals {
+		if t > 0 {
+			logTimes = append(logTimes, times[i])
+			logs = append(logs, math.Log(t))
+		}
+	}
+	if growth, err, ok := fitLine(logTimes, logs); ok {
+		forecast.add(&Model{
+			Method: MethodExponential,
+			PerDay: bounds(growth, err),
+			base:   total,
+		})
+	}
+	if window > 0 {
+		daily := make([]float64, window)
+		since := now.Add(-time.Duration(window) * 24 * time.Hour)
+		for _, pledge := range pledges {
+			if pledge.Time.After(since) && !pledge.Time.After(now) {
+				idx := int(float64(pledge.Time.Sub(since)) / day)
+				if idx >= window {
+					idx = window - 1
+				}
+				daily[idx] += float64(pledge.Amount)
+			}
+		}
+		mean, sd := meanStdDev(daily)
+		forecast.add(&Model{
+			Method: MethodMovingAverage,
+			PerDay: bounds(mean, sd/math.Sqrt(float64(window))),
+		})
+	}
+
+	return forecast
+}
+
+// add keeps model unless one of its bounds isn't a finite number, which can't
+// be published.
+func (forecast *Forecast) add(model *Model) {
+	for _, value := range []float64{model.PerDay.Expected, model.PerDay.Low, model.PerDay.High} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+	}
+	forecast.Models = append(forecast.Models, model)
+}
+
+// Goal forecasts when each of the goal's milestones will be reached. raised
+// is the current progress toward the goal, in the goal's unit.
+func (forecast *Forecast) Goal(goal *data.Goal, raised float64) {
+	forecast.Milestones = nil
+	for _, milestone := range goal.All() {
+		entry := MilestoneForecast{Milestone: milestone, Unit: goal.Unit, Reached: raised >= milestone.Amount}
+		if !entry.Reached {
+			needed := milestone.Amount - raised
+			if goal.Unit == data.GoalCells {
+				needed *= float64(forecast.price)
+			}
+			for _, model := range forecast.Models {
+				entry.Forecast = append(entry.Forecast, DateEstimate{
+					Method:   model.Method,
+					Expected: forecast.date(model, needed, model.PerDay.Expected),
+					Earliest: forecast.date(model, needed, model.PerDay.High),
+					Latest:   forecast.date(model, needed, model.PerDay.Low),
+				})
+			}
+		}
+		forecast.Milestones = append(forecast.Milestones, entry)
+	}
+}
+
+// CellsBy forecasts how many cells will have been adopted by end, starting
+// from the adopted cells there are now.
+func (forecast *Forecast) CellsBy(end time.Time, adopted int) {
+	forecast.End = &end
+	forecast.CellsAtEnd = nil
+
+	days := math.Max(0, float64(end.Sub(forecast.now))/day)
+	cells := func(model *Model, rate float64) float64 {
+		return float64(adopted) + math.Floor(model.added(days, rate)/float64(forecast.price))
+	}
+	for _, model := range forecast.Models {
+		forecast.CellsAtEnd = append(forecast.CellsAtEnd, CellEstimate{
+			Method: model.Method,
+			Bounds: Bounds{
+				Expected: cells(model, model.PerDay.Expected),
+				Low:      cells(model, model.PerDay.Low),
+				High:     cells(model, model.PerDay.High),
+			},
+		})
+	}
+}
+
+// date returns when model raises amount more dollars at rate, or nil if
+// that's beyond the horizon.
+func (forecast *Forecast) date(model *Model, amount, rate float64) *time.Time {
+	days, ok := model.days(amount, rate)
+	if !ok {
+		return nil
+	}
+	at := forecast.now.Add(time.Duration(days * day)).Round(time.Hour)
+	return &at
+}
+
+// WriteReport writes the forecast as plain text for the operator.
+func (forecast *Forecast) WriteReport(w io.Writer) error {
+	if len(forecast.Models) == 0 {
+		_, err := fmt.Fprintln(w, "Not enough pledges to forecast yet.")
+		return err
+	}
+
+	fmt.Fprintln(w, "Donation velocity:")
+	for _, model := range forecast.Models {
+		rate := model.PerDay
+		if model.Method == MethodExponential {
+			fmt.Fprintf(w, "  %-15s %.2f%% growth a day (%.2f%% to %.2f%%)\n", model.Method, 100*rate.Expected, 100*rate.Low, 100*rate.High)
+		} else {
+			fmt.Fprintf(w, "  %-15s $%.2f a day ($%.2f to $%.2f)\n", model.Method, rate.Expected, rate.Low, rate.High)
+		}
+	}
+
+	for _, milestone := range forecast.Milestones {
+		fmt.Fprintf(w, "\n%s (%g %s):\n", milestone.Name, milestone.Amount, milestone.Unit)
+		if milestone.Reached {
+			fmt.Fprintln(w, "  reached")
+			continue
+		}
+		for _, estimate := range milestone.Forecast {
+			fmt.Fprintf(w, "  %-15s %s (%s to %s)\n", estimate.Method,
+				formatDate(estimate.Expected), formatDate(estimate.Earliest), formatDate(estimate.Latest))
+		}
+	}
+
+	if forecast.End != nil {
+		fmt.Fprintf(w, "\nCells adopted by %s:\n", formatDate(forecast.End))
+		for _, estimate := range forecast.CellsAtEnd {
+			fmt.Fprintf(w, "  %-15s %.0f (%.0f to %.0f)\n", estimate.Method, estimate.Expected, estimate.Low, estimate.High)
+		}
+	}
+	return nil
+}
+
+// formatDate formats a forecast date, which may be missing.
+func formatDate(date *time.Time) string {
+	if date == nil {
+		return "never"
+	}
+	return date.Format("2006-01-02")
+}
+
+// bounds returns the 95% confidence bounds around value. Pledges only ever
+// add to the total, so the low bound stops at 0.
+func bounds(value, stdErr float64) Bounds {
+	return Bounds{
+		Expected: math.Max(0, value),
+		Low:      math.Max(0, value-z95*stdErr),
+		High:     math.Max(0, value+z95*stdErr),
+	}
+}
+
+// fitLine fits ys = a + b*xs by least squares, and returns b with its
+// standard error. At least three points spread over time are needed.
+func fitLine(xs, ys []float64) (float64, float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 3 {
+		return 0, 0, false
+	}
+	meanX, _ := meanStdDev(xs)
+	meanY, _ := meanStdDev(ys)
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+
+	var residuals float64
+	for i := range xs {
+		r := ys[i] - (intercept + slope*xs[i])
+		residuals += r * r
+	}
+	return slope, math.Sqrt(residuals / (n - 2) / sxx), true
+}
+
+// meanStdDev returns the mean and sample standard deviation of values.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
@@ -0,0 +1,106 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+)
+
+// steadyPledges returns one $100 pledge a day for days days, ending at now.
+func steadyPledges(now time.Time, days int) []data.Pledge {
+	var pledges []data.Pledge
+	for i := days - 1; i >= 0; i-- {
+		pledges = append(pledges, data.Pledge{Time: now.Add(-time.Duration(i) * 24 * time.Hour), Amount: 100})
+	}
+	return pledges
+}
+
+func TestForecastSteady(t *testing.T) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	forecast := stats.NewForecast(steadyPledges(now, 10), now, stats.DefaultWindow, 50)
+
+	if len(forecast.Models) != 3 {
+		t.Fatal("For", "models", "expected", 3, "got", len(forecast.Models))
+	}
+	for _, model := range forecast.Models {
+		if model.Method == stats.MethodExponential {
+			continue
+		}
+		if rate := model.PerDay.Expected; rate < 99 || rate > 101 {
+			t.Error("For", model.Method, "expected", 100, "got", rate)
+		}
+		if model.PerDay.Low > model.PerDay.Expected || model.PerDay.High < model.PerDay.Expected {
+			t.Error("For", model.Method, "expected", "bounds around the rate", "got", model.PerDay)
+		}
+	}
+
+	goal := &data.Goal{Unit: data.GoalDollars, Target: 1500, Milestones: []data.Milestone{{Name: "Half", Amount: 500}}}
+	forecast.Goal(goal, 1000)
+	if len(forecast.Milestones) != 2 || !forecast.Milestones[0].Reached {
+		t.Fatal("For", "milestones", "expected", "Half reached", "got", forecast.Milestones)
+	}
+	for _, estimate := range forecast.Milestones[1].Forecast {
+		if estimate.Method != stats.MethodLinear {
+			continue
+		}
+		expected := now.Add(5 * 24 * time.Hour)
+		if estimate.Expected == nil || estimate.Expected.Sub(expected).Hours() > 1 || expected.Sub(*estimate.Expected).Hours() > 1 {
+			t.Error("For", "goal date", "expected", expected, "got", estimate.Expected)
+		}
+	}
+
+	forecast.CellsBy(now.Add(10*24*time.Hour), 20)
+	for _, estimate := range forecast.CellsAtEnd {
+		if estimate.Method == stats.MethodLinear && estimate.Expected != 40 {
+			t.Error("For", "cells at end", "expected", 40, "got", estimate.Expected)
+		}
+	}
+}
+
+func TestForecastQuiet(t *testing.T) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	forecast := stats.NewForecast(nil, now, stats.DefaultWindow, 50)
+	if len(forecast.Models) != 0 {
+		t.Error("For", "no pledges", "expected", 0, "got", len(forecast.Models))
+	}
+
+	// Nothing pledged in the last week means the goal is never reached by
+	// the moving average.
+	forecast = stats.NewForecast(steadyPledges(now.Add(-30*24*time.Hour), 5), now, stats.DefaultWindow, 50)
+	forecast.Goal(&data.Goal{Unit: data.GoalCells, Target: 100}, 10)
+	for _, estimate := range forecast.Milestones[0].Forecast {
+		if estimate.Method == stats.MethodMovingAverage && estimate.Expected != nil {
+			t.Error("For", "quiet campaign", "expected", nil, "got", estimate.Expected)
+		}
+	}
+}
+
+func TestForecastLeadingZeroPledge(t *testing.T) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	pledges := []data.Pledge{
+		{Time: now.Add(-3 * 24 * time.Hour), Amount: 0},
+		{Time: now.Add(-2 * 24 * time.Hour), Amount: 50},
+		{Time: now.Add(-1 * 24 * time.Hour), Amount: 100},
+	}
+	forecast := stats.NewForecast(pledges, now, stats.DefaultWindow, 50)
+
+	exponential := false
+	for _, model := range forecast.Models {
+		for _, value := range []float64{model.PerDay.Expected, model.PerDay.Low, model.PerDay.High} {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				t.Error("For", model.Method, "expected", "finite bounds", "got", model.PerDay)
+			}
+		}
+		exponential = exponential || model.Method == stats.MethodExponential
+	}
+	if !exponential {
+		t.Error("For", "models", "expected", stats.MethodExponential, "got", forecast.Models)
+	}
+	if _, err := json.Marshal(forecast); err != nil {
+		t.Error("For", "json.Marshal()", "expected", nil, "got", err)
+	}
+}
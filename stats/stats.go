@@ -0,0 +1,182 @@
+// Package stats records the campaign's totals on every run and turns the
+// pledge history into time series for the fundraising dashboard.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+const (
+	// FileName is the name of the stats file written next to data.json.
+	FileName string = "stats.json"
+	// HistoryFile is the name of the file in the state directory holding the
+	// totals from earlier runs, one snapshot per HistoryInterval.
+	HistoryFile string = "stats_history.json"
+	// HistoryInterval is how far apart the stored snapshots are. A run inside
+	// the same interval as the last snapshot replaces it.
+	HistoryInterval = time.Hour
+
+	dayLayout string = "2006-01-02"
+)
+
+// Snapshot is the campaign's totals at the end of one run.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	data.Totals
+}
+
+// Day is the pledges made on one day, with running totals up to and
+// including it.
+type Day struct {
+	Date           string `json:"date"`
+	Pledges        int    `json:"pledges"`
+	Amount         int    `json:"amount"`
+	RunningPledges int    `json:"running_pledges"`
+	RunningAmount  int    `json:"running_amount"`
+}
+
+// Hour is the pledges made during one hour of the week, across the whole
+// campaign.
+type Hour struct {
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+	Pledges int    `json:"pledges"`
+	Amount  int    `json:"amount"`
+}
+
+// Stats is the published statistics file.
+type Stats struct {
+	Updated    time.Time  `json:"updated"`
+	Current    Snapshot   `json:"current"`
+	History    []Snapshot `json:"history"`
+	Daily      []Day      `json:"daily"`
+	HourOfWeek []Hour     `json:"hour_of_week"`
+	Forecast   *Forecast  `json:"forecast,omitempty"`
+	Channels   []Channel  `json:"channels,omitempty"`
+}
+
+// LoadHistory reads the snapshots from earlier runs. A missing file is
+// treated as an empty history.
+func LoadHistory(fileName string) ([]Snapshot, error) {
+	var history []Snapshot
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &history); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return history, nil
+}
+
+// Build works out the statistics for the current run. The pledges must be in
+// time order.
+func Build(current Snapshot, history []Snapshot, pledges []data.Pledge) *Stats {
+	stats := &Stats{
+		Updated: current.Time,
+		Current: current,
+		History: history,
+	}
+
+	// Every hour of the week is listed, even the quiet ones, so the
+	// dashboard can draw a full heat map.
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		for hour := 0; hour < 24; hour++ {
+			stats.HourOfWeek = append(stats.HourOfWeek, Hour{Weekday: weekday.String(), Hour: hour})
+		}
+	}
+
+	for _, pledge := range pledges {
+		hour := &stats.HourOfWeek[int(pledge.Time.Weekday())*24+pledge.Time.Hour()]
+		hour.Pledges++
+		hour.Amount += pledge.Amount
+	}
+	stats.Daily = daily(pledges)
+
+	return stats
+}
+
+// daily groups the pledges by day, with running totals. The pledges must be
+// in time order.
+func daily(pledges []data.Pledge) []Day {
+	days := []Day{}
+	var runningPledges, runningAmount int
+	for _, pledge := range pledges {
+		date := pledge.Time.Format(dayLayout)
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, Day{Date: date})
+		}
+		runningPledges++
+		runningAmount += pledge.Amount
+
+		day := &days[len(days)-1]
+		day.Pledges++
+		day.Amount += pledge.Amount
+		day.RunningPledges = runningPledges
+		day.RunningAmount = runningAmount
+	}
+	return days
+}
+
+// AppendHistory adds current to the history in stateDir and returns the
+// history to publish, which is the last snapshot of each day.
+func AppendHistory(stateDir string, current Snapshot) ([]Snapshot, error) {
+	historyFile := path.Join(stateDir, HistoryFile)
+	history, err := LoadHistory(historyFile)
+	if err != nil {
+		return nil, err
+	}
+	last := len(history) - 1
+	if last >= 0 && history[last].Time.Truncate(HistoryInterval).Equal(current.Time.Truncate(HistoryInterval)) {
+		history[last] = current
+	} else {
+		history = append(history, current)
+	}
+	if err := writeJSON(historyFile, history); err != nil {
+		return nil, err
+	}
+	return byDay(history), nil
+}
+
+// byDay keeps the last snapshot of each day. The history must be in time
+// order.
+func byDay(history []Snapshot) []Snapshot {
+	days := []Snapshot{}
+	for _, snapshot := range history {
+		last := len(days) - 1
+		if last >= 0 && days[last].Time.Format(dayLayout) == snapshot.Time.Format(dayLayout) {
+			days[last] = snapshot
+		} else {
+			days = append(days, snapshot)
+		}
+	}
+	return days
+}
+
+// Write publishes the statistics to fileName.
+func (stats *Stats) Write(fileName string) error {
+	return writeJSON(fileName, stats)
+}
+
+// writeJSON marshals v into the file at fileName.
+func writeJSON(fileName string, v interface{}) error {
+	err := os.MkdirAll(path.Dir(fileName), os.ModeDir|os.ModePerm)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, 0644)
+}
@@ -0,0 +1,82 @@
+package stats_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/stats"
+)
+
+func TestBuild(t *testing.T) {
+	pledges := []data.Pledge{
+		{Time: time.Date(2020, 3, 1, 9, 15, 0, 0, time.UTC), Amount: 50},
+		{Time: time.Date(2020, 3, 1, 9, 45, 0, 0, time.UTC), Amount: 25},
+		{Time: time.Date(2020, 3, 3, 20, 0, 0, 0, time.UTC), Amount: 100},
+	}
+	current := stats.Snapshot{Time: time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)}
+	result := stats.Build(current, nil, pledges)
+
+	if len(result.Daily) != 2 {
+		t.Fatal("For", "days", "expected", 2, "got", len(result.Daily))
+	}
+	first, last := result.Daily[0], result.Daily[1]
+	if first.Date != "2020-03-01" || first.Pledges != 2 || first.Amount != 75 {
+		t.Error("For", "first day", "expected", "2020-03-01 2 75", "got", first)
+	}
+	if last.RunningPledges != 3 || last.RunningAmount != 175 {
+		t.Error("For", "running totals", "expected", "3 175", "got", last)
+	}
+
+	if len(result.HourOfWeek) != 7*24 {
+		t.Fatal("For", "hours of the week", "expected", 7*24, "got", len(result.HourOfWeek))
+	}
+	// March 1st 2020 was a Sunday.
+	hour := result.HourOfWeek[9]
+	if hour.Weekday != "Sunday" || hour.Hour != 9 || hour.Amount != 75 {
+		t.Error("For", "Sunday 9:00", "expected", "75", "got", hour)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The 9:30 run replaces the 9:00 one, and only the last run of each day
+	// is published.
+	statsFile := path.Join(dir, "html", stats.FileName)
+	runs := []string{"2020-03-01 09:00", "2020-03-01 09:30", "2020-03-01 11:00", "2020-03-02 09:00"}
+	var published []stats.Snapshot
+	for i, run := range runs {
+		runTime, _ := time.ParseInLocation("2006-01-02 15:04", run, time.Local)
+		raised := 50 * (i + 1)
+		snapshot := stats.Snapshot{Time: runTime, Totals: data.Totals{Raised: raised, Donors: raised / 50}}
+		published, err = stats.AppendHistory(dir, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stats.Build(snapshot, published, nil).Write(statsFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := stats.LoadHistory(path.Join(dir, stats.HistoryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Raised != 100 || history[2].Raised != 200 {
+		t.Error("For", "history", "expected", "3 runs from 100 to 200", "got", history)
+	}
+	if len(published) != 2 || published[0].Raised != 150 || published[1].Raised != 200 {
+		t.Error("For", "published history", "expected", "150 then 200", "got", published)
+	}
+	if _, err := os.Stat(statsFile); err != nil {
+		t.Error("For", statsFile, "expected", "to exist", "got", err)
+	}
+}
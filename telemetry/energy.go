@@ -0,0 +1,226 @@
+// Package telemetry reads the solar array telemetry exports produced by the
+// strategy team and estimates how much energy each cell on the array made.
+package telemetry
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+)
+
+// maxGap is the longest stretch between two samples that is still integrated.
+// Anything longer is treated as the array being off, such as overnight.
+const maxGap time.Duration = 10 * time.Minute
+
+// timeLayouts are the timestamp formats accepted in the exports.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+}
+
+// sample is a single telemetry reading for a module or string.
+type sample struct {
+	time   time.Time
+	power  float64
+	energy float64
+}
+
+// Readings holds telemetry samples grouped by the module or string they were
+// measured on.
+type Readings struct {
+	modules map[string][]sample
+	strings map[string][]sample
+}
+
+// Load reads every CSV file matching pattern. Each file needs a timestamp
+// column, a "module" or "string" column, and either a "power" column in watts
+// or an "energy_wh" column holding the energy made since the previous row.
+func Load(pattern string) (*Readings, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no telemetry files match %q", pattern)
+	}
+
+	readings := &Readings{
+		modules: make(map[string][]sample),
+		strings: make(map[string][]sample),
+	}
+	for _, fileName := range files {
+		if err := readings.readFile(fileName); err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+	}
+
+	return readings, nil
+}
+
+// readFile adds the samples in one export to the Readings.
+func (readings *Readings) readFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	timeIdx, hasTime := lookup(cols, "timestamp", "time")
+	moduleIdx, hasModule := lookup(cols, "module")
+	stringIdx, hasString := lookup(cols, "string")
+	powerIdx, hasPower := lookup(cols, "power", "power_w")
+	energyIdx, hasEnergy := lookup(cols, "energy_wh", "energy")
+	switch {
+	case !hasTime:
+		return fmt.Errorf("missing timestamp column")
+	case !hasModule && !hasString:
+		return fmt.Errorf("missing module or string column")
+	case !hasPower && !hasEnergy:
+		return fmt.Errorf("missing power or energy_wh column")
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var s sample
+		if s.time, err = parseTime(record[timeIdx]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if hasEnergy {
+			if s.energy, err = strconv.ParseFloat(strings.TrimSpace(record[energyIdx]), 64); err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+		} else if s.power, err = strconv.ParseFloat(strings.TrimSpace(record[powerIdx]), 64); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if hasModule && record[moduleIdx] != "" {
+			name := strings.TrimSpace(record[moduleIdx])
+			readings.modules[name] = append(readings.modules[name], s)
+		} else if hasString {
+			name := strings.TrimSpace(record[stringIdx])
+			readings.strings[name] = append(readings.strings[name], s)
+		}
+	}
+
+	return nil
+}
+
+// CellEnergy estimates the energy in kWh made by every cell on the layout.
+// A unit's energy is split evenly between its cells. Module readings are
+// used where available, and string readings cover any module without them.
+func (readings *Readings) CellEnergy(layout *data.Layout) map[int]float64 {
+	moduleCells := make(map[string][]*data.Position)
+	stringCells := make(map[string][]*data.Position)
+	for _, pos := range layout.Positions() {
+		moduleCells[pos.Module] = append(moduleCells[pos.Module], pos)
+		stringCells[pos.String] = append(stringCells[pos.String], pos)
+	}
+
+	perCell := make(map[int]float64)
+	covered := make(map[string]bool)
+	for module, samples := range readings.modules {
+		cells := moduleCells[module]
+		if len(cells) == 0 {
+			continue
+		}
+		covered[module] = true
+		share := integrate(samples) / 1000 / float64(len(cells))
+		for _, pos := range cells {
+			perCell[pos.ID] += share
+		}
+	}
+
+	for str, samples := range readings.strings {
+		// Only cells in modules without their own readings use the string.
+		var cells []*data.Position
+		for _, pos := range stringCells[str] {
+			if !covered[pos.Module] {
+				cells = append(cells, pos)
+			}
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		// The string's energy is shared across all of its cells, even the
+		// ones already covered by module readings.
+		share := integrate(samples) / 1000 / float64(len(stringCells[str]))
+		for _, pos := range cells {
+			perCell[pos.ID] += share
+		}
+	}
+
+	return perCell
+}
+
+// integrate returns the energy in Wh described by the samples. Power readings
+// are integrated with the trapezoid rule, skipping gaps longer than maxGap.
+func integrate(samples []sample) float64 {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].time.Before(samples[j].time)
+	})
+
+	var wh float64
+	for i, s := range samples {
+		wh += s.energy
+		if i == 0 {
+			continue
+		}
+		prev := samples[i-1]
+		gap := s.time.Sub(prev.time)
+		if gap <= 0 || gap > maxGap {
+			continue
+		}
+		wh += (prev.power + s.power) / 2 * gap.Hours()
+	}
+	return wh
+}
+
+// lookup returns the index of the first column in names that exists.
+func lookup(cols map[string]int, names ...string) (int, bool) {
+	for _, name := range names {
+		if idx, ok := cols[name]; ok {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// parseTime tries each of the accepted timestamp formats.
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
@@ -0,0 +1,61 @@
+package telemetry_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/iAmSomeone2/aacautoupdate/data"
+	"github.com/iAmSomeone2/aacautoupdate/telemetry"
+)
+
+const testLayout string = `{
+	"modules": [
+		{"name": "A", "string": "S1", "row": 0, "col": 0, "rows": 1, "cols": 2},
+		{"name": "B", "string": "S1", "row": 0, "col": 2, "rows": 1, "cols": 2}
+	]
+}`
+
+func TestCellEnergy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	layoutFile := path.Join(dir, "layout.json")
+	ioutil.WriteFile(layoutFile, []byte(testLayout), 0644)
+	ioutil.WriteFile(path.Join(dir, "module.csv"), []byte(
+		"timestamp,module,power\n"+
+			"2020-06-01 12:00:00,A,1000\n"+
+			"2020-06-01 12:05:00,A,1000\n"+
+			"2020-06-01 12:10:00,A,1000\n"+
+			"2020-06-01 15:00:00,A,1000\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "string.csv"), []byte(
+		"timestamp,string,power\n"+
+			"2020-06-01 12:00:00,S1,2400\n"+
+			"2020-06-01 12:10:00,S1,2400\n"), 0644)
+
+	layout, err := data.LoadLayout(layoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readings, err := telemetry.Load(path.Join(dir, "*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	energy := readings.CellEnergy(layout)
+
+	// Module A made 1000 W for 10 minutes, split over two cells. The reading
+	// at 15:00 comes after a gap and is not integrated.
+	// String S1 made 2400 W for 10 minutes, split over all four of its cells,
+	// but only module B's cells fall back to it.
+	expected := map[int]float64{1: 1000.0 / 6 / 2 / 1000, 2: 1000.0 / 6 / 2 / 1000, 3: 0.1, 4: 0.1}
+	for id, kwh := range expected {
+		if math.Abs(energy[id]-kwh) > 1e-9 {
+			t.Error("For", id, "expected", kwh, "got", energy[id])
+		}
+	}
+}
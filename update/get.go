@@ -46,11 +46,14 @@ func GetCacheDir() string {
 // Additionally, if the file cannot be downloaded or the downloaded file is
 // identical to the original, "" is returned.
 func CheckForUpdate(url string) string {
+	return CheckForUpdateIn(url, path.Join(GetCacheDir(), AppDir))
+}
+
+// CheckForUpdateIn works like CheckForUpdate, but keeps the downloaded files in
+// cacheDir so that several campaigns can be checked side by side.
+func CheckForUpdateIn(url, cacheDir string) string {
 	logger := logging.NewLogger()
 	// Create the file for the contents to be read into.
-	cacheDir := GetCacheDir()
-	cacheDir = path.Join(cacheDir, AppDir)
-
 	err := os.MkdirAll(cacheDir, os.ModePerm)
 	if err != nil {
 		logger.Panic(err)
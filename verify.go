@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/iAmSomeone2/aacautoupdate/archive"
+	"github.com/iAmSomeone2/aacautoupdate/update"
+)
+
+// runVerify implements the "verify" subcommand, which checks a closed
+// campaign's archive against its signature, and optionally checks published
+// files against the copies in the archive.
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	statePtr := flags.String("state", defaultStateDir(), "The directory holding the campaign's archive.")
+	publicPtr := flags.String("pubkey", archive.PublicKeyPath(defaultKeyFile()), "A trusted copy of the signing public key. If it doesn't exist, the copy in the state directory is used.")
+	checkPtr := flags.String("check", "", "Comma separated published files, such as data.json, to compare with the archived copies.")
+	flags.Parse(args)
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	publicKey := *publicPtr
+	if _, err := os.Stat(publicKey); err != nil {
+		fmt.Println("No trusted public key found. Checking against the copy in the state directory.")
+		publicKey = ""
+	}
+	manifest, err := archive.Verify(*statePtr, publicKey)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("Archive of %q frozen %s matches its signature.\n", manifest.Campaign, manifest.FrozenAt.Format("2006-01-02 15:04"))
+
+	if *checkPtr == "" {
+		return
+	}
+	for _, fileName := range strings.Split(*checkPtr, ",") {
+		if err := manifest.Check(strings.TrimSpace(fileName)); err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s matches the archived copy.\n", strings.TrimSpace(fileName))
+	}
+}
+
+// defaultKeyFile returns where the archive signing key is kept unless
+// -signingkey says otherwise. It's in the user's config directory so that it's
+// never inside a state directory.
+func defaultKeyFile() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(configDir, update.AppDir, archive.KeyFile)
+}